@@ -0,0 +1,9 @@
+// Command migrate run the SQL migrations of package migration, see package migratecmd for the commands
+// and for building a command running Go migrations
+package main
+
+import "github.com/go-thread-7/commonlib/migration/migratecmd"
+
+func main() {
+	migratecmd.Main()
+}
@@ -153,6 +153,22 @@ func (r *Register) UpdateHandler() http.HandlerFunc {
 	})
 }
 
+// Check verify etcd is reachable and the service is still registered under its lease
+func (r *Register) Check(ctx context.Context) error {
+	if r.cli == nil {
+		return errors.New("service is not registered")
+	}
+
+	resp, err := r.cli.Get(ctx, BuildRegisterPath(r.serviceInfo), clientv3.WithCountOnly())
+	if err != nil {
+		return err
+	}
+	if resp.Count == 0 {
+		return errors.New("service registration not found")
+	}
+	return nil
+}
+
 func (r *Register) GetServerInfo() (Server, error) {
 	resp, err := r.cli.Get(context.Background(), BuildRegisterPath(r.serviceInfo))
 	if err != nil {
@@ -0,0 +1,140 @@
+package gormpg
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	createdAtColumn = "created_at"
+	updatedAtColumn = "updated_at"
+	createdByColumn = "created_by"
+	updatedByColumn = "updated_by"
+	isActiveColumn  = "is_active"
+	deletedAtColumn = "deleted_at"
+)
+
+// AuditPlugin fill created_at, updated_at, created_by and updated_by columns of entities having them,
+// the acting user is read from the statement context, see WithUser, soft deletes are not stamped as updates
+type AuditPlugin struct{}
+
+func (p *AuditPlugin) Name() string {
+	return "gormpg:audit"
+}
+
+func (p *AuditPlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("gormpg:audit_create", p.beforeCreate); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("gormpg:audit_update", p.beforeUpdate)
+}
+
+func (p *AuditPlugin) beforeCreate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	stmt := db.Statement
+	now := db.NowFunc()
+	user, hasUser := UserFromContext(stmt.Context)
+
+	db.AddError(eachEntity(stmt, func(rv reflect.Value) error {
+		if field := stmt.Schema.LookUpField(createdAtColumn); field != nil && field.AutoCreateTime == 0 {
+			if err := setIfZero(stmt, field, rv, now); err != nil {
+				return err
+			}
+		}
+		if field := stmt.Schema.LookUpField(updatedAtColumn); field != nil && field.AutoUpdateTime == 0 {
+			if err := setIfZero(stmt, field, rv, now); err != nil {
+				return err
+			}
+		}
+		if !hasUser {
+			return nil
+		}
+		for _, column := range []string{createdByColumn, updatedByColumn} {
+			if field := stmt.Schema.LookUpField(column); field != nil {
+				if err := setIfZero(stmt, field, rv, user); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}))
+}
+
+func (p *AuditPlugin) beforeUpdate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil || isSoftDelete(db.Statement) {
+		return
+	}
+
+	stmt := db.Statement
+	if field := stmt.Schema.LookUpField(updatedAtColumn); field != nil && field.AutoUpdateTime == 0 {
+		setColumn(stmt, field, db.NowFunc())
+	}
+	if user, ok := UserFromContext(stmt.Context); ok {
+		if field := stmt.Schema.LookUpField(updatedByColumn); field != nil {
+			setColumn(stmt, field, user)
+		}
+	}
+}
+
+// isSoftDelete statement only deactivates or sets the deleted at column, e.g. GenericRepository.Delete
+func isSoftDelete(stmt *gorm.Statement) bool {
+	values, ok := stmt.Dest.(map[string]interface{})
+	if !ok || len(values) != 1 {
+		return false
+	}
+	for column, value := range values {
+		field := stmt.Schema.LookUpField(column)
+		if field == nil {
+			return false
+		}
+		switch field.DBName {
+		case isActiveColumn:
+			active, ok := value.(bool)
+			return ok && !active
+		case deletedAtColumn:
+			return value != nil
+		}
+	}
+	return false
+}
+
+// eachEntity call fn for every struct value of a statement targeting a struct or a slice of structs
+func eachEntity(stmt *gorm.Statement, fn func(rv reflect.Value) error) error {
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			if err := fn(reflect.Indirect(stmt.ReflectValue.Index(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return fn(stmt.ReflectValue)
+	}
+	return nil
+}
+
+func setIfZero(stmt *gorm.Statement, field *schema.Field, rv reflect.Value, value any) error {
+	if _, zero := field.ValueOf(stmt.Context, rv); zero {
+		return field.Set(stmt.Context, rv, value)
+	}
+	return nil
+}
+
+// setColumn set an update column and keep it when the statement selects explicit columns
+func setColumn(stmt *gorm.Statement, field *schema.Field, value any) {
+	stmt.SetColumn(field.DBName, value, true)
+	if len(stmt.Selects) == 0 {
+		return
+	}
+	for _, column := range stmt.Selects {
+		if column == "*" || column == field.DBName || column == field.Name {
+			return
+		}
+	}
+	stmt.Selects = append(stmt.Selects, field.DBName)
+}
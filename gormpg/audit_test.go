@@ -0,0 +1,74 @@
+package gormpg
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+type note struct {
+	ID        int64 `gorm:"primaryKey"`
+	Text      string
+	IsActive  bool
+	UpdatedAt *time.Time `gorm:"autoUpdateTime:false"`
+	UpdatedBy string
+}
+
+func TestAuditUpdates(t *testing.T) {
+	ctx := WithUser(context.Background(), "alice")
+
+	tests := []struct {
+		name   string
+		expect func(mock sqlmock.Sqlmock)
+		run    func(r *GenericRepository[note, int64]) error
+	}{
+		{
+			name: "partial update",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "notes" SET "text"=$1,"updated_at"=$2,"updated_by"=$3 WHERE "notes"."id" = $4`)).
+					WithArgs("hello", sqlmock.AnyArg(), "alice", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			run: func(r *GenericRepository[note, int64]) error {
+				_, err := r.PartialUpdate(ctx, 1, map[string]any{"text": "hello"})
+				return err
+			},
+		},
+		{
+			name: "reactivation",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "notes" SET "is_active"=$1,"updated_at"=$2,"updated_by"=$3 WHERE "notes"."id" = $4`)).
+					WithArgs(true, sqlmock.AnyArg(), "alice", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			run: func(r *GenericRepository[note, int64]) error {
+				_, err := r.PartialUpdate(ctx, 1, map[string]any{"is_active": true})
+				return err
+			},
+		},
+		{
+			name: "soft delete",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "notes" SET "is_active"=$1 WHERE "notes"."id" = $2`)).
+					WithArgs(false, 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			run: func(r *GenericRepository[note, int64]) error {
+				return r.Delete(ctx, 1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t, &AuditPlugin{})
+			tt.expect(mock)
+
+			if err := tt.run(NewGenericRepository[note, int64](db)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
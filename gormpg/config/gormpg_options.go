@@ -1,10 +1,14 @@
 package config
 
 type GORMPostgresConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	User     string `mapstructure:"user"`
-	DBName   string `mapstructure:"dbName"`
-	SSLMode  bool   `mapstructure:"sslMode"`
-	Password string `mapstructure:"password"`
+	Host          string   `mapstructure:"host"`
+	Port          int      `mapstructure:"port"`
+	User          string   `mapstructure:"user"`
+	DBName        string   `mapstructure:"dbName"`
+	SSLMode       bool     `mapstructure:"sslMode"`
+	Password      string   `mapstructure:"password"`
+	Auditing      bool     `mapstructure:"auditing"`
+	MultiTenancy  bool     `mapstructure:"multiTenancy"`
+	Replicas      []string `mapstructure:"replicas"`
+	ReplicaPolicy string   `mapstructure:"replicaPolicy"`
 }
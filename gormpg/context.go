@@ -0,0 +1,52 @@
+package gormpg
+
+import "context"
+
+type contextKey string
+
+const (
+	userContextKey         contextKey = "gormpg.user"
+	tenantContextKey       contextKey = "gormpg.tenant"
+	bypassTenantContextKey contextKey = "gormpg.bypassTenant"
+	primaryContextKey      contextKey = "gormpg.primary"
+)
+
+// WithUser store the acting user used by the auditing plugin for created_by and updated_by
+func WithUser(ctx context.Context, user any) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+func UserFromContext(ctx context.Context) (any, bool) {
+	user := ctx.Value(userContextKey)
+	return user, user != nil
+}
+
+// WithTenant store the tenant every query and write is scoped to by the multi-tenancy plugin
+func WithTenant(ctx context.Context, tenant any) context.Context {
+	return context.WithValue(ctx, tenantContextKey, tenant)
+}
+
+func TenantFromContext(ctx context.Context) (any, bool) {
+	tenant := ctx.Value(tenantContextKey)
+	return tenant, tenant != nil
+}
+
+// WithoutTenantScope explicitly disable the tenant scope, e.g. for back office or migration jobs
+func WithoutTenantScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassTenantContextKey, true)
+}
+
+func isTenantScopeBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassTenantContextKey).(bool)
+	return bypass
+}
+
+// WithPrimary force repository reads to the primary instead of replicas, e.g. to read your own writes
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey, true)
+}
+
+func isPrimaryForced(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey).(bool)
+	return primary
+}
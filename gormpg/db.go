@@ -2,6 +2,8 @@ package gormpg
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
@@ -9,6 +11,13 @@ import (
 	"github.com/pkg/errors"
 	gorm_postgres "gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const (
+	RandomReplicaPolicy           = "random"
+	RoundRobinReplicaPolicy       = "roundRobin"
+	StrictRoundRobinReplicaPolicy = "strictRoundRobin"
 )
 
 func New(config *config.GORMPostgresConfig) (*gorm.DB, error) {
@@ -17,13 +26,7 @@ func New(config *config.GORMPostgresConfig) (*gorm.DB, error) {
 	bo.MaxElapsedTime = 10 * time.Second
 	maxRetries := 5
 
-	dsn := fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s",
-		config.Host,
-		config.Port,
-		config.User,
-		config.DBName,
-		config.Password,
-	)
+	dsn := buildDsn(config, config.Host, config.Port)
 
 	var db *gorm.DB
 	var err error
@@ -34,6 +37,87 @@ func New(config *config.GORMPostgresConfig) (*gorm.DB, error) {
 		}
 		return nil
 	}, backoff.WithMaxRetries(bo, uint64(maxRetries-1)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = registerReplicas(db, config); err != nil {
+		return nil, err
+	}
 
-	return db, err
+	if err = registerPlugins(db, config); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func buildDsn(config *config.GORMPostgresConfig, host string, port int) string {
+	return fmt.Sprintf("host=%s port=%d user=%s dbname=%s password=%s",
+		host,
+		port,
+		config.User,
+		config.DBName,
+		config.Password,
+	)
+}
+
+// registerReplicas route reads to config.Replicas, writes and transactions keep using the primary
+func registerReplicas(db *gorm.DB, config *config.GORMPostgresConfig) error {
+	if len(config.Replicas) == 0 {
+		return nil
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(config.Replicas))
+	for _, replica := range config.Replicas {
+		host, port := replica, config.Port
+		if h, p, err := net.SplitHostPort(replica); err == nil {
+			if port, err = strconv.Atoi(p); err != nil {
+				return errors.Errorf("invalid replica port: %s", replica)
+			}
+			host = h
+		}
+		replicas = append(replicas, gorm_postgres.Open(buildDsn(config, host, port)))
+	}
+
+	policy, err := replicaPolicy(config.ReplicaPolicy)
+	if err != nil {
+		return err
+	}
+
+	err = db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   policy,
+	}))
+	if err != nil {
+		return errors.Wrap(err, "failed to register read replicas")
+	}
+	return nil
+}
+
+func replicaPolicy(name string) (dbresolver.Policy, error) {
+	switch name {
+	case "", RandomReplicaPolicy:
+		return dbresolver.RandomPolicy{}, nil
+	case RoundRobinReplicaPolicy:
+		return dbresolver.RoundRobinPolicy(), nil
+	case StrictRoundRobinReplicaPolicy:
+		return dbresolver.StrictRoundRobinPolicy(), nil
+	default:
+		return nil, errors.Errorf("unknown replica policy: %s", name)
+	}
+}
+
+func registerPlugins(db *gorm.DB, config *config.GORMPostgresConfig) error {
+	if config.Auditing {
+		if err := db.Use(&AuditPlugin{}); err != nil {
+			return errors.Wrap(err, "failed to register auditing plugin")
+		}
+	}
+	if config.MultiTenancy {
+		if err := db.Use(&TenancyPlugin{}); err != nil {
+			return errors.Wrap(err, "failed to register multi-tenancy plugin")
+		}
+	}
+	return nil
 }
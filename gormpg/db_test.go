@@ -0,0 +1,113 @@
+package gormpg
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// newReplicatedMockDB gorm db on a primary and a replica sqlmock
+func newReplicatedMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	t.Helper()
+	db, primary := newMockDB(t)
+
+	replicaDB, replica, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = replicaDB.Close()
+		if err := replica.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	err = db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{postgres.New(postgres.Config{Conn: replicaDB})},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, primary, replica
+}
+
+func TestReplicaRouting(t *testing.T) {
+	tests := []struct {
+		name        string
+		run         func(r *GenericRepository[product, int64]) error
+		wantReplica bool
+		expect      func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name: "read",
+			run: func(r *GenericRepository[product, int64]) error {
+				_, err := r.GetAll(context.Background())
+				return err
+			},
+			wantReplica: true,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+		},
+		{
+			name: "count",
+			run: func(r *GenericRepository[product, int64]) error {
+				_, err := r.Count(context.Background())
+				return err
+			},
+			wantReplica: true,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "products"`)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			},
+		},
+		{
+			name: "forced primary read",
+			run: func(r *GenericRepository[product, int64]) error {
+				_, err := r.GetAll(WithPrimary(context.Background()))
+				return err
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+		},
+		{
+			name: "write",
+			run: func(r *GenericRepository[product, int64]) error {
+				return r.Delete(context.Background(), 1)
+			},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, primary, replica := newReplicatedMockDB(t)
+			if tt.wantReplica {
+				tt.expect(replica)
+			} else {
+				tt.expect(primary)
+			}
+
+			if err := tt.run(NewGenericRepository[product, int64](db)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestReplicaPolicy(t *testing.T) {
+	for _, name := range []string{"", RandomReplicaPolicy, RoundRobinReplicaPolicy, StrictRoundRobinReplicaPolicy} {
+		if _, err := replicaPolicy(name); err != nil {
+			t.Errorf("replicaPolicy(%q) error = %v", name, err)
+		}
+	}
+	if _, err := replicaPolicy("leastConnections"); err == nil {
+		t.Error("replicaPolicy() of an unknown policy succeeded")
+	}
+}
@@ -0,0 +1,113 @@
+package gormpg
+
+import (
+	"fmt"
+	"net/http"
+
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	notNullViolationCode     = "23502"
+	foreignKeyViolationCode  = "23503"
+	uniqueViolationCode      = "23505"
+	checkViolationCode       = "23514"
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+)
+
+var (
+	ErrNotFound             error = problemdetail.NewStatusError("record not found", http.StatusNotFound)
+	ErrUniqueViolation      error = problemdetail.NewStatusError("unique constraint violation", http.StatusConflict)
+	ErrForeignKeyViolation  error = problemdetail.NewStatusError("foreign key constraint violation", http.StatusConflict)
+	ErrCheckViolation       error = problemdetail.NewStatusError("check constraint violation", http.StatusUnprocessableEntity)
+	ErrNotNullViolation     error = problemdetail.NewStatusError("not null constraint violation", http.StatusUnprocessableEntity)
+	ErrSerializationFailure error = problemdetail.NewStatusError("serialization failure, transaction can be retried", http.StatusConflict)
+)
+
+// PostgresError classified postgres error, errors.Is matches its Kind and errors.As the underlying *pgconn.PgError
+type PostgresError struct {
+	Kind       error
+	Constraint string
+	Table      string
+	Column     string
+	Err        *pgconn.PgError
+}
+
+func (e *PostgresError) Error() string {
+	if e.Constraint != "" {
+		return fmt.Sprintf("%s on %s: %s", e.Kind, e.Constraint, e.Err.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Err.Message)
+}
+
+func (e *PostgresError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// StatusCode http status code used by problem details
+func (e *PostgresError) StatusCode() int {
+	var kind problemdetail.StatusCoder
+	if errors.As(e.Kind, &kind) {
+		return kind.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+// ConcurrencyConflictError returned by versioned updates when the row was changed or removed concurrently
+type ConcurrencyConflictError struct {
+	Entity  string
+	Version Version
+}
+
+func (e *ConcurrencyConflictError) Error() string {
+	return fmt.Sprintf("concurrency conflict: %s with version %d was modified or deleted", e.Entity, e.Version)
+}
+
+// StatusCode http status code used by problem details
+func (e *ConcurrencyConflictError) StatusCode() int {
+	return http.StatusConflict
+}
+
+// translateError map gorm not found and classified postgres errors to typed errors, other errors pass through
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	var kind error
+	switch pgErr.Code {
+	case uniqueViolationCode:
+		kind = ErrUniqueViolation
+	case foreignKeyViolationCode:
+		kind = ErrForeignKeyViolation
+	case checkViolationCode:
+		kind = ErrCheckViolation
+	case notNullViolationCode:
+		kind = ErrNotNullViolation
+	case serializationFailureCode, deadlockDetectedCode:
+		kind = ErrSerializationFailure
+	default:
+		return err
+	}
+
+	return &PostgresError{
+		Kind:       kind,
+		Constraint: pgErr.ConstraintName,
+		Table:      pgErr.TableName,
+		Column:     pgErr.ColumnName,
+		Err:        pgErr,
+	}
+}
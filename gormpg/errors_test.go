@@ -0,0 +1,84 @@
+package gormpg
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		wantKind   error
+		wantStatus int
+	}{
+		{name: "unique violation", code: "23505", wantKind: ErrUniqueViolation, wantStatus: http.StatusConflict},
+		{name: "foreign key violation", code: "23503", wantKind: ErrForeignKeyViolation, wantStatus: http.StatusConflict},
+		{name: "check violation", code: "23514", wantKind: ErrCheckViolation, wantStatus: http.StatusUnprocessableEntity},
+		{name: "not null violation", code: "23502", wantKind: ErrNotNullViolation, wantStatus: http.StatusUnprocessableEntity},
+		{name: "serialization failure", code: "40001", wantKind: ErrSerializationFailure, wantStatus: http.StatusConflict},
+		{name: "deadlock detected", code: "40P01", wantKind: ErrSerializationFailure, wantStatus: http.StatusConflict},
+		{name: "unclassified", code: "42P01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pgErr := &pgconn.PgError{
+				Code:           tt.code,
+				Message:        "failed",
+				ConstraintName: "products_name_key",
+				TableName:      "products",
+				ColumnName:     "name",
+			}
+			err := translateError(errors.Wrap(pgErr, "insert"))
+
+			var pgError *PostgresError
+			if !errors.As(err, &pgError) {
+				if tt.wantKind != nil {
+					t.Fatalf("translateError() = %v, want a PostgresError", err)
+				}
+				var unwrapped *pgconn.PgError
+				if !errors.As(err, &unwrapped) || unwrapped != pgErr {
+					t.Fatalf("translateError() = %v, want the error passed through", err)
+				}
+				return
+			}
+			if tt.wantKind == nil {
+				t.Fatalf("translateError() = %v, want the error passed through", err)
+			}
+			if !errors.Is(err, tt.wantKind) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.wantKind)
+			}
+			var unwrapped *pgconn.PgError
+			if !errors.As(err, &unwrapped) || unwrapped != pgErr {
+				t.Errorf("errors.As() did not return the pg error")
+			}
+			if pgError.Constraint != "products_name_key" || pgError.Table != "products" || pgError.Column != "name" {
+				t.Errorf("translateError() = %+v", pgError)
+			}
+			if status := pgError.StatusCode(); status != tt.wantStatus {
+				t.Errorf("StatusCode() = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTranslateErrorNotFound(t *testing.T) {
+	err := translateError(errors.Wrap(gorm.ErrRecordNotFound, "first"))
+	if err != ErrNotFound {
+		t.Fatalf("translateError() = %v, want ErrNotFound", err)
+	}
+	if translateError(nil) != nil {
+		t.Fatal("translateError(nil) != nil")
+	}
+}
+
+func TestPostgresErrorStatusCodeOfUnknownKind(t *testing.T) {
+	err := &PostgresError{Kind: errors.New("unknown"), Err: &pgconn.PgError{}}
+	if status := err.StatusCode(); status != http.StatusInternalServerError {
+		t.Fatalf("StatusCode() = %d, want %d", status, http.StatusInternalServerError)
+	}
+}
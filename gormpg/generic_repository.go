@@ -2,95 +2,203 @@ package gormpg
 
 import (
 	"context"
+	"reflect"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
 )
 
-// gorm generic repository
-type GenericRepository[T any] struct {
+// gorm generic repository, K is the primary key type of T (a struct with matching field names for composite keys)
+type GenericRepository[T any, K any] struct {
 	db *gorm.DB
 }
 
 // create new gorm generic repository
-func NewGenericRepository[T any](db *gorm.DB) *GenericRepository[T] {
-	return &GenericRepository[T]{
+func NewGenericRepository[T any, K any](db *gorm.DB) *GenericRepository[T, K] {
+	return &GenericRepository[T, K]{
 		db: db,
 	}
 }
 
-func (r *GenericRepository[T]) Add(ctx context.Context, entity *T) error {
-	return r.db.WithContext(ctx).Create(&entity).Error
+func (r *GenericRepository[T, K]) Add(ctx context.Context, entity *T) error {
+	return translateError(r.db.WithContext(ctx).Create(&entity).Error)
 }
 
-func (r *GenericRepository[T]) AddAll(ctx context.Context, entity *[]T) error {
-	return r.db.WithContext(ctx).Create(&entity).Error
+func (r *GenericRepository[T, K]) AddAll(ctx context.Context, entity *[]T) error {
+	return translateError(r.db.WithContext(ctx).Create(&entity).Error)
 }
 
-func (r *GenericRepository[T]) GetById(ctx context.Context, id int) (*T, error) {
+// GetById return the active entity with primary key id or ErrNotFound
+func (r *GenericRepository[T, K]) GetById(ctx context.Context, id K) (*T, error) {
 	var entity T
-	err := r.db.WithContext(ctx).Model(&entity).Where("id = ? AND is_active = ?", id, true).FirstOrInit(&entity).Error
+	condition, err := r.keyCondition(id)
 	if err != nil {
 		return nil, err
 	}
+	err = r.reader(ctx).Model(&entity).Where(condition).Where("is_active = ?", true).First(&entity).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
 	return &entity, nil
 }
 
-func (r *GenericRepository[T]) Get(ctx context.Context, params *T) *T {
+func (r *GenericRepository[T, K]) GetByIds(ctx context.Context, ids []K) (*[]T, error) {
+	var entities []T
+	if len(ids) == 0 {
+		return &entities, nil
+	}
+	fields, err := primaryFields[T](r.db)
+	if err != nil {
+		return nil, err
+	}
+	condition, err := primaryKeysCondition(fields, ids)
+	if err != nil {
+		return nil, err
+	}
+	err = r.reader(ctx).Where(condition).Where("is_active = ?", true).Find(&entities).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &entities, nil
+}
+
+// Get return the first entity matching the non-zero fields of params or ErrNotFound
+func (r *GenericRepository[T, K]) Get(ctx context.Context, params *T) (*T, error) {
 	var entity T
-	r.db.WithContext(ctx).Where(&params).FirstOrInit(&entity)
-	return &entity
+	err := r.reader(ctx).Where(&params).First(&entity).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &entity, nil
 }
 
-func (r *GenericRepository[T]) GetAll(ctx context.Context) (*[]T, error) {
+func (r *GenericRepository[T, K]) GetAll(ctx context.Context) (*[]T, error) {
 	var entities []T
-	err := r.db.WithContext(ctx).Find(&entities).Error
+	err := r.reader(ctx).Find(&entities).Error
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return &entities, nil
 }
 
-func (r *GenericRepository[T]) Where(ctx context.Context, params *T) (*[]T, error) {
+func (r *GenericRepository[T, K]) Where(ctx context.Context, params *T) (*[]T, error) {
 	var entities []T
-	err := r.db.WithContext(ctx).Where(&params).Find(&entities).Error
+	err := r.reader(ctx).Where(&params).Find(&entities).Error
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return &entities, nil
 }
 
-func (r *GenericRepository[T]) Update(ctx context.Context, entity *T) error {
-	return r.db.WithContext(ctx).Save(&entity).Error
+// Update write every column of entity by primary key or return ErrNotFound, entities with a Version field
+// fail with ConcurrencyConflictError on stale versions
+func (r *GenericRepository[T, K]) Update(ctx context.Context, entity *T) error {
+	field, err := versionField[T](r.db)
+	if err != nil {
+		return err
+	}
+	return translateError(r.update(ctx, r.db, field, entity))
 }
 
-func (r GenericRepository[T]) UpdateAll(ctx context.Context, entities *[]T) error {
-	return r.db.WithContext(ctx).Save(&entities).Error
+// UpdateAll update entities in one transaction, see Update
+func (r GenericRepository[T, K]) UpdateAll(ctx context.Context, entities *[]T) error {
+	field, err := versionField[T](r.db)
+	if err != nil {
+		return err
+	}
+	versions := make([]int64, len(*entities))
+	if field != nil {
+		for i := range *entities {
+			versions[i] = field.ReflectValueOf(ctx, reflect.ValueOf(&(*entities)[i]).Elem()).Int()
+		}
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range *entities {
+			if err := r.update(ctx, tx, field, &(*entities)[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && field != nil {
+		// the updates are rolled back, so are the versions of the entities updated before the failure
+		for i := range *entities {
+			field.ReflectValueOf(ctx, reflect.ValueOf(&(*entities)[i]).Elem()).SetInt(versions[i])
+		}
+	}
+	return translateError(err)
+}
+
+func (r *GenericRepository[T, K]) update(ctx context.Context, db *gorm.DB, versionField *schema.Field, entity *T) error {
+	if versionField == nil {
+		return updateEntity(ctx, db, entity)
+	}
+	_, err := updateWithVersion(ctx, db, versionField, entity)
+	return err
 }
 
-func (r *GenericRepository[T]) Delete(ctx context.Context, id int) error {
+// Delete deactivate the entity with primary key id or return ErrNotFound
+func (r *GenericRepository[T, K]) Delete(ctx context.Context, id K) error {
 	var entity T
-	return r.db.WithContext(ctx).FirstOrInit(&entity).UpdateColumn("is_active", false).Error
+	condition, err := r.keyCondition(id)
+	if err != nil {
+		return err
+	}
+	result := r.db.WithContext(ctx).Model(&entity).Where(condition).UpdateColumn("is_active", false)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
-func (r *GenericRepository[T]) SkipTake(ctx context.Context, skip int, take int) (*[]T, error) {
+func (r *GenericRepository[T, K]) SkipTake(ctx context.Context, skip int, take int) (*[]T, error) {
 	var entities []T
-	err := r.db.WithContext(ctx).Offset(skip).Limit(take).Find(&entities).Error
+	err := r.reader(ctx).Offset(skip).Limit(take).Find(&entities).Error
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return &entities, nil
 }
 
-func (r *GenericRepository[T]) Count(ctx context.Context) int64 {
+func (r *GenericRepository[T, K]) Count(ctx context.Context) (int64, error) {
 	var entity T
 	var count int64
-	r.db.WithContext(ctx).Model(&entity).Count(&count)
-	return count
+	err := r.reader(ctx).Model(&entity).Count(&count).Error
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return count, nil
 }
 
-func (r *GenericRepository[T]) CountWhere(ctx context.Context, params *T) int64 {
+func (r *GenericRepository[T, K]) CountWhere(ctx context.Context, params *T) (int64, error) {
 	var entity T
 	var count int64
-	r.db.WithContext(ctx).Model(&entity).Where(&params).Count(&count)
-	return count
+	err := r.reader(ctx).Model(&entity).Where(&params).Count(&count).Error
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return count, nil
+}
+
+// reader session for reads, routed to replicas when configured unless the context forces the primary
+func (r *GenericRepository[T, K]) reader(ctx context.Context) *gorm.DB {
+	db := r.db.WithContext(ctx)
+	if isPrimaryForced(ctx) {
+		db = db.Clauses(dbresolver.Write)
+	}
+	return db
+}
+
+func (r *GenericRepository[T, K]) keyCondition(id K) (clause.Expression, error) {
+	fields, err := primaryFields[T](r.db)
+	if err != nil {
+		return nil, err
+	}
+	return primaryKeyCondition(fields, id)
 }
@@ -0,0 +1,123 @@
+package gormpg
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultBatchSize = 100
+
+// UpsertOptions conflict handling of Upsert, conflicting rows are left untouched when UpdateColumns is empty
+type UpsertOptions struct {
+	ConflictColumns []string
+	UpdateColumns   []string
+	BatchSize       int
+}
+
+// AddInBatches insert entities with one statement per batch, a batch size below one uses the default batch size
+func (r *GenericRepository[T, K]) AddInBatches(ctx context.Context, entities *[]T, batchSize int) (int64, error) {
+	if len(*entities) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).CreateInBatches(entities, batchSizeOrDefault(batchSize))
+	return result.RowsAffected, translateError(result.Error)
+}
+
+// Upsert insert entities in batches with ON CONFLICT on options.ConflictColumns
+func (r *GenericRepository[T, K]) Upsert(ctx context.Context, entities *[]T, options UpsertOptions) (int64, error) {
+	if len(*entities) == 0 {
+		return 0, nil
+	}
+	if len(options.ConflictColumns) == 0 {
+		return 0, errors.New("upsert requires at least one conflict column")
+	}
+
+	onConflict := clause.OnConflict{}
+	for _, column := range options.ConflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if len(options.UpdateColumns) == 0 {
+		onConflict.DoNothing = true
+	} else {
+		onConflict.DoUpdates = clause.AssignmentColumns(options.UpdateColumns)
+	}
+
+	result := r.db.WithContext(ctx).Clauses(onConflict).CreateInBatches(entities, batchSizeOrDefault(options.BatchSize))
+	return result.RowsAffected, translateError(result.Error)
+}
+
+// PartialUpdate write only the given column values of the entity with primary key id, values of
+// versioned entities must hold the expected version which is incremented, a stale version fails
+// with ConcurrencyConflictError
+func (r *GenericRepository[T, K]) PartialUpdate(ctx context.Context, id K, values map[string]any) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	condition, err := r.keyCondition(id)
+	if err != nil {
+		return 0, err
+	}
+	field, err := versionField[T](r.db)
+	if err != nil {
+		return 0, err
+	}
+
+	var entity T
+	db := r.db.WithContext(ctx).Model(&entity).Where(condition)
+	if field == nil {
+		result := db.Updates(values)
+		return result.RowsAffected, translateError(result.Error)
+	}
+
+	s, err := parseSchema[T](r.db)
+	if err != nil {
+		return 0, err
+	}
+	expected, key, err := expectedVersion(s, field, values)
+	if err != nil {
+		return 0, err
+	}
+	assignments := make(map[string]any, len(values))
+	for column, value := range values {
+		assignments[column] = value
+	}
+	delete(assignments, key)
+	assignments[field.DBName] = gorm.Expr("? + 1", clause.Column{Name: field.DBName})
+
+	result := db.Where(versionCondition(field, expected)).Updates(assignments)
+	if result.Error != nil {
+		return 0, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, &ConcurrencyConflictError{Entity: s.Name, Version: Version(expected)}
+	}
+	return result.RowsAffected, nil
+}
+
+// UpdateSelected write only the given columns of entity, zero values included, versioned entities
+// are checked and incremented like in Update
+func (r *GenericRepository[T, K]) UpdateSelected(ctx context.Context, entity *T, columns ...string) (int64, error) {
+	if len(columns) == 0 {
+		return 0, errors.New("update requires at least one selected column")
+	}
+	field, err := versionField[T](r.db)
+	if err != nil {
+		return 0, err
+	}
+	if field != nil {
+		rows, err := updateWithVersion(ctx, r.db, field, entity, columns...)
+		return rows, translateError(err)
+	}
+	result := r.db.WithContext(ctx).Model(entity).Select(columns).Updates(entity)
+	return result.RowsAffected, translateError(result.Error)
+}
+
+func batchSizeOrDefault(batchSize int) int {
+	if batchSize < 1 {
+		return defaultBatchSize
+	}
+	return batchSize
+}
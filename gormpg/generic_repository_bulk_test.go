@@ -0,0 +1,184 @@
+package gormpg
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+)
+
+type tag struct {
+	ID   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name string
+}
+
+func TestAddInBatches(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "tags" ("id","name") VALUES ($1,$2),($3,$4)`)).
+		WithArgs(1, "a", 2, "b").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "tags" ("id","name") VALUES ($1,$2)`)).
+		WithArgs(3, "c").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rows, err := NewGenericRepository[tag, int64](db).AddInBatches(context.Background(), &[]tag{{1, "a"}, {2, "b"}, {3, "c"}}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 3 {
+		t.Errorf("AddInBatches() rows = %d, want 3", rows)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	tests := []struct {
+		name    string
+		options UpsertOptions
+		sql     string
+		wantErr bool
+	}{
+		{
+			name:    "update columns",
+			options: UpsertOptions{ConflictColumns: []string{"id"}, UpdateColumns: []string{"name"}},
+			sql:     `INSERT INTO "tags" ("id","name") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "name"="excluded"."name"`,
+		},
+		{
+			name:    "do nothing",
+			options: UpsertOptions{ConflictColumns: []string{"name"}},
+			sql:     `INSERT INTO "tags" ("id","name") VALUES ($1,$2) ON CONFLICT ("name") DO NOTHING`,
+		},
+		{
+			name:    "without conflict columns",
+			options: UpsertOptions{UpdateColumns: []string{"name"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			if tt.sql != "" {
+				mock.ExpectExec(regexp.QuoteMeta(tt.sql)).WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			rows, err := NewGenericRepository[tag, int64](db).Upsert(context.Background(), &[]tag{{1, "a"}}, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Upsert() error = %v, want error %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && rows != 1 {
+				t.Errorf("Upsert() rows = %d, want 1", rows)
+			}
+		})
+	}
+}
+
+func TestPartialUpdate(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "tags" SET "name"=$1 WHERE "tags"."id" = $2`)).
+		WithArgs("b", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rows, err := NewGenericRepository[tag, int64](db).PartialUpdate(context.Background(), 1, map[string]any{"name": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("PartialUpdate() rows = %d, want 1", rows)
+	}
+}
+
+func TestUpdateSelected(t *testing.T) {
+	db, mock := newMockDB(t)
+	// zero values of selected columns are written
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "tags" SET "name"=$1 WHERE "id" = $2`)).
+		WithArgs("", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := NewGenericRepository[tag, int64](db)
+	rows, err := r.UpdateSelected(context.Background(), &tag{ID: 1}, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("UpdateSelected() rows = %d, want 1", rows)
+	}
+	if _, err := r.UpdateSelected(context.Background(), &tag{ID: 1}); err == nil {
+		t.Error("UpdateSelected() without columns succeeded")
+	}
+}
+
+func TestPartialUpdateWithVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		values       map[string]any
+		rowsAffected int64
+		wantConflict bool
+		wantErr      bool
+	}{
+		{name: "current version", values: map[string]any{"count": 5, "version": Version(3)}, rowsAffected: 1},
+		{name: "field name", values: map[string]any{"count": 5, "Version": 3}, rowsAffected: 1},
+		{name: "stale version", values: map[string]any{"count": 5, "version": Version(3)}, wantConflict: true},
+		{name: "without version", values: map[string]any{"count": 5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			if !tt.wantErr {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "stocks" SET "count"=$1,"version"="version" + 1 WHERE "stocks"."id" = $2 AND "stocks"."version" = $3`)).
+					WithArgs(5, 1, 3).
+					WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+			}
+
+			rows, err := NewGenericRepository[stock, int64](db).PartialUpdate(context.Background(), 1, tt.values)
+
+			var conflict *ConcurrencyConflictError
+			if errors.As(err, &conflict) != tt.wantConflict {
+				t.Fatalf("PartialUpdate() error = %v, want conflict %t", err, tt.wantConflict)
+			}
+			if tt.wantConflict && conflict.Version != 3 {
+				t.Errorf("conflict version = %d, want 3", conflict.Version)
+			}
+			if (err != nil) != (tt.wantErr || tt.wantConflict) {
+				t.Fatalf("PartialUpdate() error = %v, want error %t", err, tt.wantErr || tt.wantConflict)
+			}
+			if rows != tt.rowsAffected {
+				t.Errorf("PartialUpdate() rows = %d, want %d", rows, tt.rowsAffected)
+			}
+		})
+	}
+}
+
+func TestUpdateSelectedWithVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		wantVersion  Version
+		wantConflict bool
+	}{
+		{name: "current version", rowsAffected: 1, wantVersion: 4},
+		{name: "stale version", rowsAffected: 0, wantVersion: 3, wantConflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectExec(regexp.QuoteMeta(`UPDATE "stocks" SET "count"=$1,"version"=$2 WHERE "stocks"."version" = $3 AND "id" = $4`)).
+				WithArgs(0, 4, 3, 1).
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+
+			entity := &stock{ID: 1, Version: 3}
+			rows, err := NewGenericRepository[stock, int64](db).UpdateSelected(context.Background(), entity, "count")
+
+			var conflict *ConcurrencyConflictError
+			if errors.As(err, &conflict) != tt.wantConflict {
+				t.Fatalf("UpdateSelected() error = %v, want conflict %t", err, tt.wantConflict)
+			}
+			if rows != tt.rowsAffected {
+				t.Errorf("UpdateSelected() rows = %d, want %d", rows, tt.rowsAffected)
+			}
+			if entity.Version != tt.wantVersion {
+				t.Errorf("entity version = %d, want %d", entity.Version, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package gormpg
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-thread-7/commonlib/gormpg/outbox"
+	"gorm.io/gorm"
+)
+
+// AddWithEvents insert entity and write events to the outbox in the same transaction
+func (r *GenericRepository[T, K]) AddWithEvents(ctx context.Context, entity *T, events ...outbox.Event) error {
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entity).Error; err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	}))
+}
+
+// UpdateWithEvents update entity like Update and write events to the outbox in the same transaction
+func (r *GenericRepository[T, K]) UpdateWithEvents(ctx context.Context, entity *T, events ...outbox.Event) error {
+	field, err := versionField[T](r.db)
+	if err != nil {
+		return err
+	}
+	var version int64
+	if field != nil {
+		version = field.ReflectValueOf(ctx, reflect.ValueOf(entity).Elem()).Int()
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := r.update(ctx, tx, field, entity); err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+	if err != nil && field != nil {
+		// the update is rolled back with the events, so is the version
+		field.ReflectValueOf(ctx, reflect.ValueOf(entity).Elem()).SetInt(version)
+	}
+	return translateError(err)
+}
@@ -0,0 +1,86 @@
+package gormpg
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-thread-7/commonlib/gormpg/outbox"
+	"github.com/pkg/errors"
+)
+
+func TestUpdateWithEvents(t *testing.T) {
+	updateSQL := regexp.QuoteMeta(`UPDATE "invoices" SET "tenant_id"=$1,"amount"=$2 WHERE "invoices"."tenant_id" = $3 AND "id" = $4`)
+	event := outbox.Event{AggregateType: "invoice", AggregateID: "1", EventType: "InvoiceUpdated", Payload: map[string]int{"amount": 10}}
+
+	tests := []struct {
+		name   string
+		entity invoice
+		expect func(mock sqlmock.Sqlmock)
+		want   error
+	}{
+		{
+			name:   "updated",
+			entity: invoice{ID: 1, Amount: 10},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(updateSQL).WithArgs("a", 10, "a", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "outbox_messages"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "attempts"}).AddRow(1, 0))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			// the events are rolled back with the update of a missing row instead of inserting it
+			name:   "not found",
+			entity: invoice{ID: 1, Amount: 10},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(updateSQL).WithArgs("a", 10, "a", 1).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			want: ErrNotFound,
+		},
+		{
+			name:   "entity of another tenant",
+			entity: invoice{ID: 1, TenantID: "b", Amount: 10},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			want: ErrCrossTenant,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t, &TenancyPlugin{})
+			tt.expect(mock)
+
+			r := NewGenericRepository[invoice, int64](db)
+			err := r.UpdateWithEvents(WithTenant(context.Background(), "a"), &tt.entity, event)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("UpdateWithEvents() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateWithEventsRestoresVersion(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "stocks" SET "count"=$1,"version"=$2 WHERE "stocks"."version" = $3 AND "id" = $4`)).
+		WithArgs(5, 4, 3, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "outbox_messages"`)).WillReturnError(errors.New("outbox unavailable"))
+	mock.ExpectRollback()
+
+	entity := &stock{ID: 1, Count: 5, Version: 3}
+	event := outbox.Event{AggregateType: "stock", AggregateID: "1", EventType: "StockChanged", Payload: []byte(`{}`)}
+	if err := NewGenericRepository[stock, int64](db).UpdateWithEvents(context.Background(), entity, event); err == nil {
+		t.Fatal("UpdateWithEvents() succeeded although the events were not written")
+	}
+	if entity.Version != 3 {
+		t.Errorf("entity version = %d, want 3", entity.Version)
+	}
+}
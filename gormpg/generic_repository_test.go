@@ -0,0 +1,154 @@
+package gormpg
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type product struct {
+	ID       int64 `gorm:"primaryKey"`
+	Name     string
+	IsActive bool
+}
+
+// newMockDB gorm db on sqlmock, statements are matched by regular expression
+func newMockDB(t *testing.T, plugins ...gorm.Plugin) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plugin := range plugins {
+		if err := db.Use(plugin); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db, mock
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		want         error
+	}{
+		{name: "existing", rowsAffected: 1},
+		{name: "missing", rowsAffected: 0, want: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "is_active"=$1 WHERE "products"."id" = $2`)).
+				WithArgs(false, 7).
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+
+			err := NewGenericRepository[product, int64](db).Delete(context.Background(), 7)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+type orderLine struct {
+	OrderID  int64 `gorm:"primaryKey"`
+	LineNo   int   `gorm:"primaryKey"`
+	Sku      string
+	IsActive bool
+}
+
+type orderLineKey struct {
+	OrderID int64
+	LineNo  int
+}
+
+func TestGetByIdCompositeKey(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "order_lines" WHERE ("order_lines"."order_id" = $1 AND "order_lines"."line_no" = $2) AND is_active = $3`)).
+		WithArgs(int64(7), 2, true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"order_id", "line_no", "sku", "is_active"}).AddRow(7, 2, "sku-2", true))
+
+	line, err := NewGenericRepository[orderLine, orderLineKey](db).GetById(context.Background(), orderLineKey{OrderID: 7, LineNo: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line.OrderID != 7 || line.LineNo != 2 || line.Sku != "sku-2" {
+		t.Fatalf("GetById() = %+v", line)
+	}
+}
+
+func TestGetByIdCompositeKeyNotStruct(t *testing.T) {
+	db, _ := newMockDB(t)
+
+	_, err := NewGenericRepository[orderLine, int64](db).GetById(context.Background(), 7)
+	if err == nil {
+		t.Fatal("GetById() with a scalar key of a composite primary key should fail")
+	}
+}
+
+func TestGetByIds(t *testing.T) {
+	t.Run("single key", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE "products"."id" IN ($1,$2) AND is_active = $3`)).
+			WithArgs(1, 2, true).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "is_active"}).AddRow(1, "a", true).AddRow(2, "b", true))
+
+		products, err := NewGenericRepository[product, int64](db).GetByIds(context.Background(), []int64{1, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*products) != 2 {
+			t.Fatalf("GetByIds() returned %d products, want 2", len(*products))
+		}
+	})
+
+	t.Run("composite key", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "order_lines" WHERE ("order_lines"."order_id","order_lines"."line_no") IN (($1,$2),($3,$4)) AND is_active = $5`)).
+			WithArgs(int64(7), 1, int64(8), 3, true).
+			WillReturnRows(sqlmock.NewRows([]string{"order_id", "line_no", "sku", "is_active"}).AddRow(7, 1, "a", true).AddRow(8, 3, "b", true))
+
+		keys := []orderLineKey{{OrderID: 7, LineNo: 1}, {OrderID: 8, LineNo: 3}}
+		lines, err := NewGenericRepository[orderLine, orderLineKey](db).GetByIds(context.Background(), keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*lines) != 2 || (*lines)[1].LineNo != 3 {
+			t.Fatalf("GetByIds() = %+v", *lines)
+		}
+	})
+
+	t.Run("empty ids", func(t *testing.T) {
+		// no query is expected, the mock fails on any statement
+		db, _ := newMockDB(t)
+
+		products, err := NewGenericRepository[product, int64](db).GetByIds(context.Background(), []int64{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if products == nil || len(*products) != 0 {
+			t.Fatalf("GetByIds() = %v, want an empty slice", products)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package gormpg
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/go-thread-7/commonlib/gormpg/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// Notification postgres notification received on a listened channel
+type Notification struct {
+	Channel string
+	Payload string
+	PID     uint32
+}
+
+// NotificationHandler handle a notification, returned errors are logged
+type NotificationHandler func(ctx context.Context, notification *Notification) error
+
+// notificationConn connection listening for notifications, a *pgx.Conn outside of the tests
+type notificationConn interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// Listener postgres LISTEN/NOTIFY subscriber using a dedicated connection,
+// notifications sent while the connection is lost are not delivered
+type Listener struct {
+	config  *config.GORMPostgresConfig
+	connect func(ctx context.Context) (notificationConn, error)
+	backOff func() backoff.BackOff
+}
+
+// NewListener create listener with the connection settings used by New
+func NewListener(config *config.GORMPostgresConfig) *Listener {
+	l := &Listener{config: config}
+	l.connect = func(ctx context.Context) (notificationConn, error) {
+		return pgx.Connect(ctx, buildDsn(l.config, l.config.Host, l.config.Port))
+	}
+	l.backOff = func() backoff.BackOff {
+		bo := backoff.NewExponentialBackOff()
+		bo.MaxElapsedTime = 0
+		return bo
+	}
+	return l
+}
+
+// Listen LISTEN on channels and call handler for every notification until ctx is canceled, the connection
+// is re-established with exponential backoff and channels listened again after connection loss, errors that
+// a retry cannot fix, e.g. a failed authentication or an unknown database, are returned
+func (l *Listener) Listen(ctx context.Context, handler NotificationHandler, channels ...string) error {
+	if len(channels) == 0 {
+		return errors.New("listen requires at least one channel")
+	}
+
+	bo := l.backOff()
+	for {
+		err := l.listen(ctx, handler, channels, bo)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if permanentError(err) {
+			return err
+		}
+
+		delay := bo.NextBackOff()
+		log.Printf("[gormpg_Listener.Listen] connection lost: %v, reconnecting in %s\n", err, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Subscribe LISTEN on channels and deliver notifications on the returned channel, closed when ctx is canceled
+// or when Listen fails with an error a retry cannot fix, which is logged
+func (l *Listener) Subscribe(ctx context.Context, channels ...string) (<-chan *Notification, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("subscribe requires at least one channel")
+	}
+
+	notifications := make(chan *Notification)
+	go func() {
+		defer close(notifications)
+		err := l.Listen(ctx, func(ctx context.Context, notification *Notification) error {
+			select {
+			case notifications <- notification:
+			case <-ctx.Done():
+			}
+			return nil
+		}, channels...)
+		if err != nil {
+			log.Printf("[gormpg_Listener.Subscribe] listen error: %v\n", err)
+		}
+	}()
+	return notifications, nil
+}
+
+func (l *Listener) listen(ctx context.Context, handler NotificationHandler, channels []string, bo backoff.BackOff) error {
+	conn, err := l.connect(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect postgres")
+	}
+	defer conn.Close(context.Background())
+
+	for _, channel := range channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return errors.Wrapf(err, "failed to listen on channel %s", channel)
+		}
+	}
+	bo.Reset()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = handler(ctx, &Notification{
+			Channel: notification.Channel,
+			Payload: notification.Payload,
+			PID:     notification.PID,
+		})
+		if err != nil {
+			log.Printf("[gormpg_Listener.listen] handle notification on channel %s error: %v\n", notification.Channel, err)
+		}
+	}
+}
+
+// permanentError report errors a reconnection cannot fix: invalid connection settings, failed authentication,
+// unknown databases and LISTEN statements rejected by the server
+func permanentError(err error) bool {
+	var configErr *pgconn.ParseConfigError
+	if errors.As(err, &configErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || len(pgErr.Code) < 2 {
+		return false
+	}
+	switch pgErr.Code[:2] {
+	// invalid authorization specification, invalid catalog name, syntax error or access rule violation
+	case "28", "3D", "42":
+		return true
+	}
+	return false
+}
+
+// JSONHandler adapt a handler of typed payloads, notification payloads are decoded from json
+func JSONHandler[T any](handler func(ctx context.Context, channel string, payload T) error) NotificationHandler {
+	return func(ctx context.Context, notification *Notification) error {
+		var payload T
+		if err := json.Unmarshal([]byte(notification.Payload), &payload); err != nil {
+			return errors.Wrapf(err, "failed to decode payload of channel %s", notification.Channel)
+		}
+		return handler(ctx, notification.Channel, payload)
+	}
+}
+
+// Notify send payload encoded as json to channel, inside a transaction it is delivered on commit
+func Notify(ctx context.Context, db *gorm.DB, channel string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode notification payload")
+	}
+	return db.WithContext(ctx).Clauses(dbresolver.Write).Exec("SELECT pg_notify(?, ?)", channel, string(data)).Error
+}
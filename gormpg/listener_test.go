@@ -0,0 +1,219 @@
+package gormpg
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// fakeConn notification connection delivering notifications until they are closed, which fails the connection
+type fakeConn struct {
+	notifications chan *pgconn.Notification
+
+	mu       sync.Mutex
+	listened []string
+}
+
+func newFakeConn(notifications ...*pgconn.Notification) *fakeConn {
+	c := &fakeConn{notifications: make(chan *pgconn.Notification, len(notifications))}
+	for _, notification := range notifications {
+		c.notifications <- notification
+	}
+	return c
+}
+
+func (c *fakeConn) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listened = append(c.listened, sql)
+	return pgconn.NewCommandTag("LISTEN"), nil
+}
+
+func (c *fakeConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case notification, ok := <-c.notifications:
+		if !ok {
+			return nil, errors.New("unexpected EOF")
+		}
+		return notification, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *fakeConn) Close(context.Context) error {
+	return nil
+}
+
+func (c *fakeConn) statements() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.listened...)
+}
+
+// newFakeListener listener connecting with connect and reconnecting without delay
+func newFakeListener(connect func(ctx context.Context) (notificationConn, error)) *Listener {
+	return &Listener{
+		connect: connect,
+		backOff: func() backoff.BackOff { return &backoff.ZeroBackOff{} },
+	}
+}
+
+func TestListenReconnects(t *testing.T) {
+	lost := newFakeConn(&pgconn.Notification{Channel: "orders", Payload: "1"})
+	close(lost.notifications)
+	reconnected := newFakeConn(&pgconn.Notification{Channel: "orders", Payload: "2"})
+	conns := []*fakeConn{lost, reconnected}
+
+	var mu sync.Mutex
+	connects := 0
+	l := newFakeListener(func(context.Context) (notificationConn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		connects++
+		if connects == 2 {
+			// the server is down for one attempt
+			return nil, errors.New("connection refused")
+		}
+		conn := conns[0]
+		conns = conns[1:]
+		return conn, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var payloads []string
+	err := l.Listen(ctx, func(_ context.Context, notification *Notification) error {
+		payloads = append(payloads, notification.Payload)
+		if len(payloads) == 2 {
+			cancel()
+		}
+		return nil
+	}, "orders", "invoices")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(payloads, ",") != "1,2" {
+		t.Errorf("payloads = %v, want [1 2]", payloads)
+	}
+	want := `LISTEN "orders",LISTEN "invoices"`
+	for i, conn := range []*fakeConn{lost, reconnected} {
+		if got := strings.Join(conn.statements(), ","); got != want {
+			t.Errorf("connection %d statements = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestListenPermanentError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		permanent bool
+	}{
+		{name: "invalid password", err: &pgconn.PgError{Code: "28P01"}, permanent: true},
+		{name: "unknown database", err: &pgconn.PgError{Code: "3D000"}, permanent: true},
+		{name: "invalid config", err: &pgconn.ParseConfigError{}, permanent: true},
+		{name: "server shutdown", err: &pgconn.PgError{Code: "57P01"}},
+		{name: "network", err: errors.New("connection refused")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connects := 0
+			l := newFakeListener(func(context.Context) (notificationConn, error) {
+				connects++
+				if connects > 1 {
+					return newFakeConn(), nil
+				}
+				return nil, errors.Wrap(tt.err, "failed to connect postgres")
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := l.Listen(ctx, func(context.Context, *Notification) error { return nil }, "orders")
+			if (err != nil) != tt.permanent {
+				t.Fatalf("Listen() error = %v, want permanent %t", err, tt.permanent)
+			}
+			if tt.permanent && connects != 1 {
+				t.Errorf("connects = %d, want 1", connects)
+			}
+		})
+	}
+}
+
+func TestSubscribeClosesOnCancel(t *testing.T) {
+	conn := newFakeConn(&pgconn.Notification{Channel: "orders", Payload: "1"})
+	l := newFakeListener(func(context.Context) (notificationConn, error) { return conn, nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifications, err := l.Subscribe(ctx, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case notification := <-notifications:
+		if notification.Payload != "1" {
+			t.Fatalf("payload = %s, want 1", notification.Payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification delivered")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-notifications:
+		if ok {
+			t.Fatal("notification delivered after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notifications not closed after cancel")
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	type order struct {
+		ID int64 `json:"id"`
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		want    int64
+		wantErr bool
+	}{
+		{name: "decoded", payload: `{"id":7}`, want: 7},
+		{name: "invalid json", payload: `{"id":`, wantErr: true},
+		{name: "wrong type", payload: `{"id":"7"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := JSONHandler(func(_ context.Context, channel string, payload order) error {
+				called = true
+				if channel != "orders" || payload.ID != tt.want {
+					t.Errorf("handler(%s, %+v), want orders and id %d", channel, payload, tt.want)
+				}
+				return nil
+			})
+
+			err := handler(context.Background(), &Notification{Channel: "orders", Payload: tt.payload})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("JSONHandler() error = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "orders") {
+				t.Errorf("error %q does not name the channel", err)
+			}
+			if called == tt.wantErr {
+				t.Errorf("handler called = %t, want %t", called, !tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package gormpg
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Version opt-in optimistic locking column, entities having a Version field are updated
+// only when the stored version matches and the version is incremented on every update
+type Version int64
+
+var versionType = reflect.TypeOf(Version(0))
+
+// versionField return the Version field of entity or nil when entity is not versioned
+func versionField[T any](db *gorm.DB) (*schema.Field, error) {
+	s, err := parseSchema[T](db)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range s.Fields {
+		if field.FieldType == versionType && field.DBName != "" {
+			return field, nil
+		}
+	}
+	return nil, nil
+}
+
+// updateWithVersion update entity where the version column equals the entity version and
+// increment it, only columns and the version are written when columns are given,
+// entity version is left unchanged when the update fails
+func updateWithVersion[T any](ctx context.Context, db *gorm.DB, field *schema.Field, entity *T, columns ...string) (int64, error) {
+	rv := reflect.ValueOf(entity).Elem()
+	s, err := parseSchema[T](db)
+	if err != nil {
+		return 0, err
+	}
+	if err := requirePrimaryKey(ctx, s, rv); err != nil {
+		return 0, err
+	}
+
+	selected := []string{"*"}
+	if len(columns) > 0 {
+		selected = append(append([]string{}, columns...), field.DBName)
+	}
+
+	version := field.ReflectValueOf(ctx, rv)
+	expected := version.Int()
+	version.SetInt(expected + 1)
+
+	result := db.WithContext(ctx).
+		Model(entity).
+		Where(versionCondition(field, expected)).
+		Select(selected).
+		Updates(entity)
+	err = result.Error
+	if err == nil && result.RowsAffected == 0 {
+		err = &ConcurrencyConflictError{Entity: s.Name, Version: Version(expected)}
+	}
+	if err != nil {
+		version.SetInt(expected)
+		return 0, err
+	}
+	return result.RowsAffected, nil
+}
+
+// expectedVersion take the expected version out of the column values of a partial update,
+// the version is looked up by column and by field name
+func expectedVersion(s *schema.Schema, field *schema.Field, values map[string]any) (int64, string, error) {
+	for _, key := range []string{field.DBName, field.Name} {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		v := reflect.Indirect(reflect.ValueOf(value))
+		if !v.CanInt() {
+			return 0, "", errors.Errorf("version of %s must be an integer, got %T", s.Name, value)
+		}
+		return v.Int(), key, nil
+	}
+	return 0, "", errors.Errorf("partial update of versioned %s requires its expected %s", s.Name, field.DBName)
+}
+
+func versionCondition(field *schema.Field, version int64) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: version}
+}
+
+// updateEntity update every column of entity by primary key or return ErrNotFound,
+// unlike Save it never falls back to an insert
+func updateEntity[T any](ctx context.Context, db *gorm.DB, entity *T) error {
+	s, err := parseSchema[T](db)
+	if err != nil {
+		return err
+	}
+	if err := requirePrimaryKey(ctx, s, reflect.ValueOf(entity).Elem()); err != nil {
+		return err
+	}
+
+	result := db.WithContext(ctx).Model(entity).Select("*").Updates(entity)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package gormpg
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+)
+
+type stock struct {
+	ID      int64 `gorm:"primaryKey"`
+	Count   int
+	Version Version
+}
+
+func TestUpdateWithVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		wantVersion  Version
+		wantConflict bool
+	}{
+		{name: "current version", rowsAffected: 1, wantVersion: 4},
+		{name: "stale version", rowsAffected: 0, wantVersion: 3, wantConflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectExec(regexp.QuoteMeta(`UPDATE "stocks" SET "count"=$1,"version"=$2 WHERE "stocks"."version" = $3 AND "id" = $4`)).
+				WithArgs(5, 4, 3, 1).
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+
+			entity := &stock{ID: 1, Count: 5, Version: 3}
+			err := NewGenericRepository[stock, int64](db).Update(context.Background(), entity)
+
+			var conflict *ConcurrencyConflictError
+			if errors.As(err, &conflict) != tt.wantConflict {
+				t.Fatalf("Update() error = %v, want conflict %t", err, tt.wantConflict)
+			}
+			if tt.wantConflict && conflict.Version != 3 {
+				t.Errorf("conflict version = %d, want 3", conflict.Version)
+			}
+			if entity.Version != tt.wantVersion {
+				t.Errorf("entity version = %d, want %d", entity.Version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestUpdateWithVersionRequiresPrimaryKey(t *testing.T) {
+	db, _ := newMockDB(t)
+	entity := &stock{Count: 5, Version: 3}
+	if err := NewGenericRepository[stock, int64](db).Update(context.Background(), entity); err == nil {
+		t.Fatal("Update() without primary key succeeded")
+	}
+	if entity.Version != 3 {
+		t.Errorf("entity version = %d, want 3", entity.Version)
+	}
+}
+
+func TestUpdateAllWithVersionRollsBack(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "stocks"`)).WithArgs(5, 2, 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "stocks"`)).WithArgs(6, 8, 7, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	entities := &[]stock{{ID: 1, Count: 5, Version: 1}, {ID: 2, Count: 6, Version: 7}}
+	err := NewGenericRepository[stock, int64](db).UpdateAll(context.Background(), entities)
+	var conflict *ConcurrencyConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("UpdateAll() error = %v, want conflict", err)
+	}
+	for i, want := range []Version{1, 7} {
+		if (*entities)[i].Version != want {
+			t.Errorf("entity %d version = %d, want %d", i, (*entities)[i].Version, want)
+		}
+	}
+}
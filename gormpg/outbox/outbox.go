@@ -0,0 +1,78 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const TableName = "outbox_messages"
+
+// Message outbox row, messages of the same aggregate are relayed in id order
+type Message struct {
+	ID            int64      `gorm:"primaryKey;autoIncrement"`
+	AggregateType string     `gorm:"size:255;not null;index:idx_outbox_messages_aggregate,priority:1"`
+	AggregateID   string     `gorm:"size:255;not null;index:idx_outbox_messages_aggregate,priority:2"`
+	EventType     string     `gorm:"size:255;not null"`
+	Payload       []byte     `gorm:"type:jsonb;not null"`
+	Attempts      int        `gorm:"not null;default:0"`
+	LastError     string     `gorm:"type:text"`
+	NextAttemptAt time.Time  `gorm:"not null;index"`
+	CreatedAt     time.Time  `gorm:"not null"`
+	SentAt        *time.Time `gorm:"index"`
+}
+
+func (Message) TableName() string {
+	return TableName
+}
+
+// Event to be published, Payload is marshaled to json unless it is already a []byte or json.RawMessage
+type Event struct {
+	AggregateType string
+	AggregateID   string
+	EventType     string
+	Payload       any
+}
+
+// Migrate create or update the outbox table
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Message{})
+}
+
+// Write insert events into the outbox, tx must be the transaction writing the aggregate
+func Write(tx *gorm.DB, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := tx.NowFunc()
+	messages := make([]Message, len(events))
+	for i, event := range events {
+		payload, err := marshalPayload(event.Payload)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal %s event payload", event.EventType)
+		}
+		messages[i] = Message{
+			AggregateType: event.AggregateType,
+			AggregateID:   event.AggregateID,
+			EventType:     event.EventType,
+			Payload:       payload,
+			NextAttemptAt: now,
+		}
+	}
+
+	return tx.Create(&messages).Error
+}
+
+func marshalPayload(payload any) ([]byte, error) {
+	switch p := payload.(type) {
+	case json.RawMessage:
+		return p, nil
+	case []byte:
+		return p, nil
+	default:
+		return json.Marshal(payload)
+	}
+}
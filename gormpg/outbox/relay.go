@@ -0,0 +1,221 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPollInterval    = time.Second
+	defaultBatchSize       = 100
+	defaultInitialBackoff  = time.Second
+	defaultMaxBackoff      = 5 * time.Minute
+	defaultRetention       = time.Hour
+	defaultCleanupInterval = time.Minute
+)
+
+// Publisher hand a message to the message broker, a returned error schedules a retry
+type Publisher interface {
+	Publish(ctx context.Context, message *Message) error
+}
+
+type PublisherFunc func(ctx context.Context, message *Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, message *Message) error {
+	return f(ctx, message)
+}
+
+// RelayOptions zero values use defaults
+type RelayOptions struct {
+	PollInterval    time.Duration
+	BatchSize       int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	Retention       time.Duration
+	CleanupInterval time.Duration
+}
+
+// Relay poll unsent outbox messages and publish them, several relays can run concurrently
+type Relay struct {
+	db          *gorm.DB
+	publisher   Publisher
+	options     RelayOptions
+	lastCleanup time.Time
+}
+
+func NewRelay(db *gorm.DB, publisher Publisher, options RelayOptions) *Relay {
+	if options.PollInterval <= 0 {
+		options.PollInterval = defaultPollInterval
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultBatchSize
+	}
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = defaultInitialBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultMaxBackoff
+	}
+	if options.Retention <= 0 {
+		options.Retention = defaultRetention
+	}
+	if options.CleanupInterval <= 0 {
+		options.CleanupInterval = defaultCleanupInterval
+	}
+
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		options:   options,
+	}
+}
+
+// Run relay messages until ctx is canceled, failed batches are logged and retried on the next poll
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			count, err := r.relayBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[outbox_Relay.Run] relay batch error: %v\n", err)
+				}
+				break
+			}
+			// full batches mean more messages are waiting, relay them without waiting for the next tick
+			if count < r.options.BatchSize {
+				break
+			}
+		}
+
+		r.cleanup(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch lock the oldest unsent message of aggregates, skipping messages locked by other relays, then the
+// later unsent messages of those aggregates up to the batch size, and publish them in id order, once a message
+// of an aggregate fails its later messages wait for the retry which keeps the per aggregate order
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		messages, err := r.lockMessages(tx)
+		if err != nil {
+			return err
+		}
+		count = len(messages)
+
+		failed := map[aggregate]bool{}
+		for i := range messages {
+			message := &messages[i]
+			key := aggregate{message.AggregateType, message.AggregateID}
+			if failed[key] {
+				continue
+			}
+			updates := map[string]any{"attempts": message.Attempts + 1}
+
+			if err := r.publisher.Publish(ctx, message); err != nil {
+				log.Printf("[outbox_Relay.relayBatch] publish message %d of %s %s failed: %v\n", message.ID, message.AggregateType, message.AggregateID, err)
+				failed[key] = true
+				updates["last_error"] = err.Error()
+				updates["next_attempt_at"] = tx.NowFunc().Add(r.backoff(message.Attempts + 1))
+			} else {
+				updates["last_error"] = ""
+				updates["sent_at"] = tx.NowFunc()
+			}
+
+			if err := tx.Model(message).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+type aggregate struct {
+	aggregateType string
+	aggregateID   string
+}
+
+// lockMessages lock the due oldest unsent message of aggregates and the later unsent messages of the same
+// aggregates, other relays cannot lock those later messages since they only lock the oldest one
+func (r *Relay) lockMessages(tx *gorm.DB) ([]Message, error) {
+	var heads []Message
+	err := tx.Raw(fmt.Sprintf(`SELECT m.* FROM %[1]s m
+		WHERE m.sent_at IS NULL AND m.next_attempt_at <= ?
+		AND NOT EXISTS (
+			SELECT 1 FROM %[1]s p
+			WHERE p.aggregate_type = m.aggregate_type AND p.aggregate_id = m.aggregate_id
+			AND p.sent_at IS NULL AND p.id < m.id
+		)
+		ORDER BY m.id
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED`, TableName), tx.NowFunc(), r.options.BatchSize).Scan(&heads).Error
+	if err != nil || len(heads) == 0 || len(heads) >= r.options.BatchSize {
+		return heads, err
+	}
+
+	aggregates := make([][]any, len(heads))
+	ids := make([]int64, len(heads))
+	for i, head := range heads {
+		aggregates[i] = []any{head.AggregateType, head.AggregateID}
+		ids[i] = head.ID
+	}
+
+	var followers []Message
+	err = tx.Raw(fmt.Sprintf(`SELECT m.* FROM %s m
+		WHERE m.sent_at IS NULL AND (m.aggregate_type, m.aggregate_id) IN ? AND m.id NOT IN ?
+		ORDER BY m.id
+		LIMIT ?
+		FOR UPDATE`, TableName), aggregates, ids, r.options.BatchSize-len(heads)).Scan(&followers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	messages := append(heads, followers...)
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+	return messages, nil
+}
+
+// backoff exponential delay before the next attempt capped by MaxBackoff
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.options.InitialBackoff
+	for i := 1; i < attempts && delay < r.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.options.MaxBackoff {
+		delay = r.options.MaxBackoff
+	}
+	return delay
+}
+
+// cleanup delete messages sent before the retention period
+func (r *Relay) cleanup(ctx context.Context) {
+	if time.Since(r.lastCleanup) < r.options.CleanupInterval {
+		return
+	}
+	r.lastCleanup = time.Now()
+
+	err := r.db.WithContext(ctx).
+		Where("sent_at IS NOT NULL AND sent_at < ?", r.db.NowFunc().Add(-r.options.Retention)).
+		Delete(&Message{}).Error
+	if err != nil && ctx.Err() == nil {
+		log.Printf("[outbox_Relay.cleanup] delete sent messages error: %v\n", err)
+	}
+}
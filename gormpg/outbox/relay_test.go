@@ -0,0 +1,144 @@
+package outbox
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:  logger.Discard,
+		NowFunc: func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, mock
+}
+
+func messageRows(messages ...Message) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "aggregate_type", "aggregate_id", "event_type", "payload", "attempts", "next_attempt_at"})
+	for _, m := range messages {
+		rows.AddRow(m.ID, m.AggregateType, m.AggregateID, "created", []byte("{}"), m.Attempts, now)
+	}
+	return rows
+}
+
+func TestRelayBatch(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	// oldest unsent message of every aggregate
+	mock.ExpectQuery(`SELECT m\.\* FROM outbox_messages m\s+WHERE m\.sent_at IS NULL AND m\.next_attempt_at <= \$1.*FOR UPDATE SKIP LOCKED`).
+		WithArgs(now, 10).
+		WillReturnRows(messageRows(
+			Message{ID: 1, AggregateType: "order", AggregateID: "a"},
+			Message{ID: 2, AggregateType: "order", AggregateID: "b"},
+		))
+	// later messages of the locked aggregates
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE m.sent_at IS NULL AND (m.aggregate_type, m.aggregate_id) IN (($1,$2),($3,$4)) AND m.id NOT IN ($5,$6)`)).
+		WithArgs("order", "a", "order", "b", 1, 2, 8).
+		WillReturnRows(messageRows(
+			Message{ID: 3, AggregateType: "order", AggregateID: "a"},
+			Message{ID: 4, AggregateType: "order", AggregateID: "b"},
+			Message{ID: 5, AggregateType: "order", AggregateID: "b"},
+			Message{ID: 6, AggregateType: "order", AggregateID: "a"},
+		))
+	sent := regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"last_error"=$2,"sent_at"=$3 WHERE "id" = $4`)
+	failed := regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"last_error"=$2,"next_attempt_at"=$3 WHERE "id" = $4`)
+	mock.ExpectExec(sent).WithArgs(1, "", now, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(sent).WithArgs(1, "", now, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(failed).WithArgs(1, "broker unavailable", now.Add(time.Second), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(sent).WithArgs(1, "", now, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(sent).WithArgs(1, "", now, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var published []int64
+	relay := NewRelay(db, PublisherFunc(func(ctx context.Context, message *Message) error {
+		published = append(published, message.ID)
+		if message.ID == 3 {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}), RelayOptions{BatchSize: 10})
+
+	count, err := relay.relayBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 {
+		t.Errorf("relayBatch() count = %d, want 6", count)
+	}
+	// message 6 waits for the retry of message 3 of the same aggregate
+	want := []int64{1, 2, 3, 4, 5}
+	if len(published) != len(want) {
+		t.Fatalf("published %v, want %v", published, want)
+	}
+	for i := range want {
+		if published[i] != want[i] {
+			t.Fatalf("published %v, want %v", published, want)
+		}
+	}
+}
+
+func TestRelayBatchFullOfHeads(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE SKIP LOCKED`).
+		WithArgs(now, 1).
+		WillReturnRows(messageRows(Message{ID: 1, AggregateType: "order", AggregateID: "a", Attempts: 2}))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"last_error"=$2,"sent_at"=$3 WHERE "id" = $4`)).
+		WithArgs(3, "", now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	relay := NewRelay(db, PublisherFunc(func(ctx context.Context, message *Message) error {
+		return nil
+	}), RelayOptions{BatchSize: 1})
+
+	if count, err := relay.relayBatch(context.Background()); err != nil || count != 1 {
+		t.Fatalf("relayBatch() = %d, %v", count, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	relay := NewRelay(nil, nil, RelayOptions{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := relay.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package gormpg
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// parseSchema parse entity schema with gorm naming strategy, parsed schemas are cached by gorm
+func parseSchema[T any](db *gorm.DB) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, errors.Wrap(err, "failed to parse entity schema")
+	}
+	return stmt.Schema, nil
+}
+
+// primaryFields return primary key fields of entity
+func primaryFields[T any](db *gorm.DB) ([]*schema.Field, error) {
+	s, err := parseSchema[T](db)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.PrimaryFields) == 0 {
+		return nil, errors.Errorf("entity %s has no primary key", s.Name)
+	}
+	return s.PrimaryFields, nil
+}
+
+// requirePrimaryKey fail when a primary key field of entity is zero, an update without key would
+// otherwise match every row in scope
+func requirePrimaryKey(ctx context.Context, s *schema.Schema, rv reflect.Value) error {
+	for _, field := range s.PrimaryFields {
+		if _, zero := field.ValueOf(ctx, rv); zero {
+			return errors.Errorf("update of %s requires primary key %s", s.Name, field.Name)
+		}
+	}
+	return nil
+}
+
+// keyValues split key into primary key values, composite keys must be structs
+// having a field with the same name for every primary key field of the entity
+func keyValues(fields []*schema.Field, key any) ([]any, error) {
+	if len(fields) == 1 {
+		return []any{key}, nil
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(key))
+	if v.Kind() != reflect.Struct {
+		return nil, errors.Errorf("composite primary key requires a struct key, got %T", key)
+	}
+
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		fv := v.FieldByName(field.Name)
+		if !fv.IsValid() {
+			return nil, errors.Errorf("key %T has no field %s", key, field.Name)
+		}
+		values[i] = fv.Interface()
+	}
+	return values, nil
+}
+
+func keyColumn(field *schema.Field) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+}
+
+// primaryKeyCondition build where condition matching a single key
+func primaryKeyCondition(fields []*schema.Field, key any) (clause.Expression, error) {
+	values, err := keyValues(fields, key)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs := make([]clause.Expression, len(fields))
+	for i, field := range fields {
+		exprs[i] = clause.Eq{Column: keyColumn(field), Value: values[i]}
+	}
+	return clause.And(exprs...), nil
+}
+
+// primaryKeysCondition build where condition matching any of keys, composite keys use tuple IN
+func primaryKeysCondition[K any](fields []*schema.Field, keys []K) (clause.Expression, error) {
+	if len(fields) == 1 {
+		values := make([]any, len(keys))
+		for i, key := range keys {
+			values[i] = key
+		}
+		return clause.IN{Column: keyColumn(fields[0]), Values: values}, nil
+	}
+
+	columns := make([]clause.Column, len(fields))
+	for i, field := range fields {
+		columns[i] = keyColumn(field)
+	}
+
+	tuples := make([]any, len(keys))
+	for i, key := range keys {
+		values, err := keyValues(fields, key)
+		if err != nil {
+			return nil, err
+		}
+		tuples[i] = values
+	}
+	return clause.IN{Column: columns, Values: tuples}, nil
+}
@@ -0,0 +1,188 @@
+package gormpg
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultTextSearchConfig = "simple"
+
+// Scope composable query condition of Query and CountQuery, column names are quoted and values bound as parameters
+type Scope func(db *gorm.DB) *gorm.DB
+
+func column(name string) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: name}
+}
+
+// array bind values as one postgres array parameter, gorm expands a plain slice into a list of parameters
+func array[V any](values []V) pgtype.Array[V] {
+	return pgtype.Array[V]{
+		Elements: values,
+		Dims:     []pgtype.ArrayDimension{{Length: int32(len(values)), LowerBound: 1}},
+		Valid:    true,
+	}
+}
+
+// addOrder append an ordering expression to the ORDER BY clause, unlike Order it keeps expressions
+// with bound values, e.g. ts_rank of a query
+func addOrder(db *gorm.DB, expr clause.Expression) *gorm.DB {
+	var exprs []clause.Expression
+	if c, ok := db.Statement.Clauses["ORDER BY"]; ok {
+		if orderBy, ok := c.Expression.(clause.OrderBy); ok {
+			if orderBy.Expression != nil {
+				exprs = append(exprs, orderBy.Expression)
+			}
+			for _, orderByColumn := range orderBy.Columns {
+				exprs = append(exprs, orderColumn(orderByColumn.Column, orderByColumn.Desc))
+			}
+		}
+	}
+	return db.Clauses(clause.OrderBy{Expression: clause.CommaExpression{Exprs: append(exprs, expr)}})
+}
+
+func orderColumn(c clause.Column, desc bool) clause.Expression {
+	if desc {
+		return clause.Expr{SQL: "? DESC", Vars: []any{c}}
+	}
+	return clause.Expr{SQL: "?", Vars: []any{c}}
+}
+
+func textSearchConfig(config string) string {
+	if config == "" {
+		return defaultTextSearchConfig
+	}
+	return config
+}
+
+// Filter match the non-zero fields of params like Where
+func Filter[T any](params *T) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(params)
+	}
+}
+
+// Paginate skip and take rows
+func Paginate(skip int, take int) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Offset(skip).Limit(take)
+	}
+}
+
+// OrderBy order by column
+func OrderBy(name string, desc bool) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return addOrder(db, orderColumn(column(name), desc))
+	}
+}
+
+// JSONContains match jsonb column containing value encoded as json (@>)
+func JSONContains(name string, value any) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		data, err := json.Marshal(value)
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		return db.Where("? @> ?::jsonb", column(name), string(data))
+	}
+}
+
+// JSONHasKey match jsonb column having top level key
+func JSONHasKey(name string, key string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("jsonb_exists(?, ?)", column(name), key)
+	}
+}
+
+// JSONPathEquals match jsonb column whose text value at path equals value (#>>)
+func JSONPathEquals(name string, path []string, value string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("? #>> ?::text[] = ?", column(name), array(path), value)
+	}
+}
+
+// JSONPathMatch match jsonb column for which the sql/json path query returns any item, e.g. $.tags[*] ? (@ == "go")
+func JSONPathMatch(name string, jsonPath string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("jsonb_path_exists(?, ?::jsonpath)", column(name), jsonPath)
+	}
+}
+
+// TextSearch match tsvector column against a web search style query, an empty config uses the simple configuration
+func TextSearch(name string, query string, config string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("? @@ websearch_to_tsquery(?::regconfig, ?)", column(name), textSearchConfig(config), query)
+	}
+}
+
+// TextSearchRanked match like TextSearch and order by ts_rank, best matches first, ordering scopes after it break ties
+func TextSearchRanked(name string, query string, config string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		searchConfig := textSearchConfig(config)
+		db = db.Where("? @@ websearch_to_tsquery(?::regconfig, ?)", column(name), searchConfig, query)
+		return addOrder(db, clause.Expr{
+			SQL:  "ts_rank(?, websearch_to_tsquery(?::regconfig, ?)) DESC",
+			Vars: []any{column(name), searchConfig, query},
+		})
+	}
+}
+
+// ArrayContains match array column containing all values (@>)
+func ArrayContains[V any](name string, values []V) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("? @> ?", column(name), array(values))
+	}
+}
+
+// ArrayContainedBy match array column whose elements are all in values (<@)
+func ArrayContainedBy[V any](name string, values []V) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("? <@ ?", column(name), array(values))
+	}
+}
+
+// ArrayOverlaps match array column having any element in values (&&)
+func ArrayOverlaps[V any](name string, values []V) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("? && ?", column(name), array(values))
+	}
+}
+
+// ArrayHas match array column having value as element
+func ArrayHas(name string, value any) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("? = ANY(?)", value, column(name))
+	}
+}
+
+// Query return entities matching all scopes
+func (r *GenericRepository[T, K]) Query(ctx context.Context, scopes ...Scope) (*[]T, error) {
+	var entities []T
+	err := applyScopes(r.reader(ctx), scopes).Find(&entities).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &entities, nil
+}
+
+// CountQuery count entities matching all scopes, pagination and ordering scopes are ignored
+func (r *GenericRepository[T, K]) CountQuery(ctx context.Context, scopes ...Scope) (int64, error) {
+	var entity T
+	var count int64
+	err := applyScopes(r.reader(ctx).Model(&entity), scopes).Offset(-1).Limit(-1).Count(&count).Error
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return count, nil
+}
+
+func applyScopes(db *gorm.DB, scopes []Scope) *gorm.DB {
+	for _, scope := range scopes {
+		db = scope(db)
+	}
+	return db
+}
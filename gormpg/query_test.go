@@ -0,0 +1,98 @@
+package gormpg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+type document struct {
+	ID     int64 `gorm:"primaryKey"`
+	Search string
+	Tags   pq.StringArray `gorm:"type:text[]"`
+	Rank   int
+}
+
+func TestQueryScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []Scope
+		wantSQL  string
+		wantVars []any
+	}{
+		{
+			name:     "ranked text search",
+			scopes:   []Scope{TextSearchRanked("search", "go orm", "")},
+			wantSQL:  `SELECT * FROM "documents" WHERE "documents"."search" @@ websearch_to_tsquery($1::regconfig, $2) ORDER BY ts_rank("documents"."search", websearch_to_tsquery($3::regconfig, $4)) DESC`,
+			wantVars: []any{"simple", "go orm", "simple", "go orm"},
+		},
+		{
+			name:     "ranked text search with tie breaker",
+			scopes:   []Scope{TextSearchRanked("search", "go", "english"), OrderBy("id", false)},
+			wantSQL:  `SELECT * FROM "documents" WHERE "documents"."search" @@ websearch_to_tsquery($1::regconfig, $2) ORDER BY ts_rank("documents"."search", websearch_to_tsquery($3::regconfig, $4)) DESC, "documents"."id"`,
+			wantVars: []any{"english", "go", "english", "go"},
+		},
+		{
+			name:    "order by columns",
+			scopes:  []Scope{OrderBy("rank", true), OrderBy("id", false)},
+			wantSQL: `SELECT * FROM "documents" ORDER BY "documents"."rank" DESC, "documents"."id"`,
+		},
+		{
+			name:     "array contains",
+			scopes:   []Scope{ArrayContains("tags", []string{"go", "sql"})},
+			wantSQL:  `SELECT * FROM "documents" WHERE "documents"."tags" @> $1`,
+			wantVars: []any{array([]string{"go", "sql"})},
+		},
+		{
+			name:     "array overlaps",
+			scopes:   []Scope{ArrayOverlaps("tags", []string{"go"})},
+			wantSQL:  `SELECT * FROM "documents" WHERE "documents"."tags" && $1`,
+			wantVars: []any{array([]string{"go"})},
+		},
+		{
+			name:     "json path equals",
+			scopes:   []Scope{JSONPathEquals("search", []string{"a", "b"}, "c")},
+			wantSQL:  `SELECT * FROM "documents" WHERE "documents"."search" #>> $1::text[] = $2`,
+			wantVars: []any{array([]string{"a", "b"}), "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newMockDB(t)
+			var documents []document
+			stmt := applyScopes(db.Session(&gorm.Session{DryRun: true}), tt.scopes).Find(&documents).Statement
+
+			if got := stmt.SQL.String(); got != tt.wantSQL {
+				t.Errorf("sql = %s\nwant %s", got, tt.wantSQL)
+			}
+			if len(tt.wantVars) > 0 && !reflect.DeepEqual(stmt.Vars, tt.wantVars) {
+				t.Errorf("vars = %#v, want %#v", stmt.Vars, tt.wantVars)
+			}
+		})
+	}
+}
+
+func TestCountQueryIgnoresOrder(t *testing.T) {
+	db, _ := newMockDB(t)
+	var count int64
+	stmt := applyScopes(db.Session(&gorm.Session{DryRun: true}).Model(&document{}), []Scope{TextSearchRanked("search", "go", "")}).
+		Count(&count).Statement
+
+	want := `SELECT count(*) FROM "documents" WHERE "documents"."search" @@ websearch_to_tsquery($1::regconfig, $2)`
+	if got := stmt.SQL.String(); got != want {
+		t.Errorf("sql = %s\nwant %s", got, want)
+	}
+}
+
+func TestArrayEncoding(t *testing.T) {
+	buf, err := pgtype.NewMap().Encode(pgtype.TextArrayOID, pgtype.TextFormatCode, array([]string{"go", "sql"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "{go,sql}" {
+		t.Errorf("encoded array = %s, want {go,sql}", buf)
+	}
+}
@@ -0,0 +1,201 @@
+package gormpg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const tenantColumn = "tenant_id"
+
+var (
+	ErrTenantRequired = errors.New("tenant is required in context for tenant scoped entities")
+	ErrCrossTenant    = errors.New("entity belongs to another tenant")
+)
+
+// TenancyPlugin scope every query and write on entities having a tenant_id column to the context tenant,
+// statements without tenant fail with ErrTenantRequired unless WithoutTenantScope is set,
+// upserts only update conflicting rows of the tenant and never change the tenant column
+type TenancyPlugin struct{}
+
+func (p *TenancyPlugin) Name() string {
+	return "gormpg:tenancy"
+}
+
+func (p *TenancyPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("gormpg:tenant_create", p.beforeCreate); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("gormpg:tenant_query", p.scope); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("gormpg:tenant_row", p.scope); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("gormpg:tenant_update", p.beforeUpdate); err != nil {
+		return err
+	}
+	return callbacks.Delete().Before("gorm:delete").Register("gormpg:tenant_delete", p.scope)
+}
+
+// tenant return tenant field and context tenant, field is nil when the statement is not tenant scoped
+func (p *TenancyPlugin) tenant(db *gorm.DB) (*schema.Field, any) {
+	if db.Error != nil || db.Statement.Schema == nil || isTenantScopeBypassed(db.Statement.Context) {
+		return nil, nil
+	}
+	field := db.Statement.Schema.LookUpField(tenantColumn)
+	if field == nil {
+		return nil, nil
+	}
+	tenant, ok := TenantFromContext(db.Statement.Context)
+	if !ok {
+		db.AddError(ErrTenantRequired)
+		return nil, nil
+	}
+	return field, tenant
+}
+
+func (p *TenancyPlugin) scope(db *gorm.DB) {
+	field, tenant := p.tenant(db)
+	if field == nil {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenant},
+	}})
+}
+
+func (p *TenancyPlugin) beforeCreate(db *gorm.DB) {
+	field, tenant := p.tenant(db)
+	if field == nil {
+		return
+	}
+	if err := eachEntity(db.Statement, func(rv reflect.Value) error {
+		return assignTenant(db.Statement, field, rv, tenant)
+	}); err != nil {
+		db.AddError(err)
+		return
+	}
+	scopeUpsert(db.Statement, field, tenant)
+}
+
+// scopeUpsert restrict ON CONFLICT DO UPDATE to rows of the tenant so that a conflicting key of another
+// tenant leaves that row untouched, the tenant column itself is never updated
+func scopeUpsert(stmt *gorm.Statement, field *schema.Field, tenant any) {
+	c, ok := stmt.Clauses["ON CONFLICT"]
+	if !ok {
+		return
+	}
+	onConflict, ok := c.Expression.(clause.OnConflict)
+	if !ok || onConflict.DoNothing {
+		return
+	}
+
+	if onConflict.UpdateAll {
+		onConflict.UpdateAll = false
+		onConflict.DoUpdates = updateAllAssignments(stmt)
+		if len(onConflict.Columns) == 0 && onConflict.OnConstraint == "" {
+			for _, primaryField := range stmt.Schema.PrimaryFields {
+				onConflict.Columns = append(onConflict.Columns, clause.Column{Name: primaryField.DBName})
+			}
+		}
+	}
+
+	assignments := make(clause.Set, 0, len(onConflict.DoUpdates))
+	for _, assignment := range onConflict.DoUpdates {
+		if assignment.Column.Name != field.DBName && assignment.Column.Name != field.Name {
+			assignments = append(assignments, assignment)
+		}
+	}
+	onConflict.DoUpdates = assignments
+	if len(assignments) == 0 {
+		onConflict.DoNothing = true
+	} else {
+		onConflict.Where.Exprs = append(onConflict.Where.Exprs,
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenant})
+	}
+	stmt.AddClause(onConflict)
+}
+
+// updateAllAssignments expand ON CONFLICT UpdateAll to the updatable columns gorm would set,
+// so that the tenant column can be left out
+func updateAllAssignments(stmt *gorm.Statement) clause.Set {
+	selectColumns, restricted := stmt.SelectAndOmitColumns(true, true)
+	now := stmt.DB.NowFunc()
+
+	var assignments clause.Set
+	for _, dbName := range stmt.Schema.DBNames {
+		field := stmt.Schema.FieldsByDBName[dbName]
+		if v, ok := selectColumns[dbName]; (ok && !v) || (!ok && restricted) {
+			continue
+		}
+		if field.PrimaryKey || !field.Creatable || field.AutoCreateTime > 0 ||
+			field.HasDefaultValue && field.DefaultValueInterface == nil && !strings.EqualFold(field.DefaultValue, "NULL") {
+			continue
+		}
+		if field.AutoUpdateTime > 0 {
+			assignments = append(assignments, clause.Assignment{Column: clause.Column{Name: dbName}, Value: autoUpdateTime(field, now)})
+			continue
+		}
+		assignments = append(assignments, clause.AssignmentColumns([]string{dbName})...)
+	}
+	return assignments
+}
+
+func autoUpdateTime(field *schema.Field, now time.Time) any {
+	switch field.AutoUpdateTime {
+	case schema.UnixNanosecond:
+		return now.UnixNano()
+	case schema.UnixMillisecond:
+		return now.UnixMilli()
+	case schema.UnixSecond:
+		return now.Unix()
+	}
+	return now
+}
+
+func (p *TenancyPlugin) beforeUpdate(db *gorm.DB) {
+	field, tenant := p.tenant(db)
+	if field == nil {
+		return
+	}
+
+	if values, ok := db.Statement.Dest.(map[string]interface{}); ok {
+		for _, key := range []string{field.DBName, field.Name} {
+			if value, ok := values[key]; ok && !sameTenant(value, tenant) {
+				db.AddError(ErrCrossTenant)
+				return
+			}
+		}
+	} else if err := eachEntity(db.Statement, func(rv reflect.Value) error {
+		return assignTenant(db.Statement, field, rv, tenant)
+	}); err != nil {
+		db.AddError(err)
+		return
+	}
+
+	p.scope(db)
+}
+
+// assignTenant set the tenant of entities without tenant and reject entities of another tenant
+func assignTenant(stmt *gorm.Statement, field *schema.Field, rv reflect.Value, tenant any) error {
+	value, zero := field.ValueOf(stmt.Context, rv)
+	if zero {
+		return field.Set(stmt.Context, rv, tenant)
+	}
+	if !sameTenant(value, tenant) {
+		return ErrCrossTenant
+	}
+	return nil
+}
+
+func sameTenant(value any, tenant any) bool {
+	return fmt.Sprint(value) == fmt.Sprint(tenant)
+}
@@ -0,0 +1,162 @@
+package gormpg
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+)
+
+type invoice struct {
+	ID       int64 `gorm:"primaryKey;autoIncrement:false"`
+	TenantID string
+	Amount   int
+}
+
+func TestTenancyWrites(t *testing.T) {
+	tenantA := WithTenant(context.Background(), "a")
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		expect func(mock sqlmock.Sqlmock)
+		run    func(ctx context.Context, r *GenericRepository[invoice, int64]) error
+		want   error
+	}{
+		{
+			// the row of tenant b matches no row in the scope of tenant a, and is not inserted over
+			name: "update of another tenant row",
+			ctx:  tenantA,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "invoices" SET "tenant_id"=$1,"amount"=$2 WHERE "invoices"."tenant_id" = $3 AND "id" = $4`)).
+					WithArgs("a", 10, "a", 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			run: func(ctx context.Context, r *GenericRepository[invoice, int64]) error {
+				return r.Update(ctx, &invoice{ID: 1, Amount: 10})
+			},
+			want: ErrNotFound,
+		},
+		{
+			name:   "update of an entity of another tenant",
+			ctx:    tenantA,
+			expect: func(mock sqlmock.Sqlmock) {},
+			run: func(ctx context.Context, r *GenericRepository[invoice, int64]) error {
+				return r.Update(ctx, &invoice{ID: 1, TenantID: "b", Amount: 10})
+			},
+			want: ErrCrossTenant,
+		},
+		{
+			name: "update all of another tenant row",
+			ctx:  tenantA,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "invoices" SET "tenant_id"=$1,"amount"=$2 WHERE "invoices"."tenant_id" = $3 AND "id" = $4`)).
+					WithArgs("a", 10, "a", 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			run: func(ctx context.Context, r *GenericRepository[invoice, int64]) error {
+				return r.UpdateAll(ctx, &[]invoice{{ID: 1, Amount: 10}})
+			},
+			want: ErrNotFound,
+		},
+		{
+			// a conflicting row of tenant b is left untouched by the tenant predicate
+			name: "upsert",
+			ctx:  tenantA,
+			expect: func(mock sqlmock.Sqlmock) {
+				for _, id := range []int64{1, 2} {
+					mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "invoices" ("id","tenant_id","amount") VALUES ($1,$2,$3) ON CONFLICT ("id") DO UPDATE SET "amount"="excluded"."amount" WHERE "invoices"."tenant_id" = $4`)).
+						WithArgs(id, "a", 10, "a").
+						WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+			},
+			run: func(ctx context.Context, r *GenericRepository[invoice, int64]) error {
+				_, err := r.Upsert(ctx, &[]invoice{{ID: 1, Amount: 10}, {ID: 2, Amount: 10}}, UpsertOptions{
+					ConflictColumns: []string{"id"},
+					UpdateColumns:   []string{"tenant_id", "amount"},
+					BatchSize:       1,
+				})
+				return err
+			},
+		},
+		{
+			name: "upsert of the tenant column only",
+			ctx:  tenantA,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "invoices" ("id","tenant_id","amount") VALUES ($1,$2,$3) ON CONFLICT ("id") DO NOTHING`)).
+					WithArgs(1, "a", 10).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			run: func(ctx context.Context, r *GenericRepository[invoice, int64]) error {
+				_, err := r.Upsert(ctx, &[]invoice{{ID: 1, Amount: 10}}, UpsertOptions{
+					ConflictColumns: []string{"id"},
+					UpdateColumns:   []string{"tenant_id"},
+				})
+				return err
+			},
+		},
+		{
+			name: "save of a slice",
+			ctx:  tenantA,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "invoices" ("id","tenant_id","amount") VALUES ($1,$2,$3) ON CONFLICT ("id") DO UPDATE SET "amount"="excluded"."amount" WHERE "invoices"."tenant_id" = $4`)).
+					WithArgs(1, "a", 10, "a").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			run: func(ctx context.Context, r *GenericRepository[invoice, int64]) error {
+				return r.db.WithContext(ctx).Save(&[]invoice{{ID: 1, Amount: 10}}).Error
+			},
+		},
+		{
+			name:   "without tenant",
+			ctx:    context.Background(),
+			expect: func(mock sqlmock.Sqlmock) {},
+			run: func(ctx context.Context, r *GenericRepository[invoice, int64]) error {
+				return r.Update(ctx, &invoice{ID: 1, Amount: 10})
+			},
+			want: ErrTenantRequired,
+		},
+		{
+			name: "bypass",
+			ctx:  WithoutTenantScope(context.Background()),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "invoices" SET "tenant_id"=$1,"amount"=$2 WHERE "id" = $3`)).
+					WithArgs("b", 10, 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			run: func(ctx context.Context, r *GenericRepository[invoice, int64]) error {
+				return r.Update(ctx, &invoice{ID: 1, TenantID: "b", Amount: 10})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t, &TenancyPlugin{})
+			tt.expect(mock)
+
+			err := tt.run(tt.ctx, NewGenericRepository[invoice, int64](db))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenancyReads(t *testing.T) {
+	db, mock := newMockDB(t, &TenancyPlugin{})
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "invoices" WHERE "invoices"."tenant_id" = $1`)).
+		WithArgs("a").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "amount"}).AddRow(1, "a", 10))
+
+	invoices, err := NewGenericRepository[invoice, int64](db).GetAll(WithTenant(context.Background(), "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*invoices) != 1 || (*invoices)[0].TenantID != "a" {
+		t.Fatalf("GetAll() = %+v", *invoices)
+	}
+}
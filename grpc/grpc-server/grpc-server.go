@@ -25,15 +25,17 @@ type GrpcServer struct {
 	Config *config.GRPCConfig
 }
 
-func New(config *config.GRPCConfig) *GrpcServer {
-	s := grpc.NewServer(
+// New create grpc server, opts are appended to the default keepalive options, e.g. grpc.ChainUnaryInterceptor
+func New(config *config.GRPCConfig, opts ...grpc.ServerOption) *GrpcServer {
+	serverOptions := []grpc.ServerOption{
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			MaxConnectionIdle: maxConnectionIdle * time.Minute,
 			Timeout:           gRPCTimeout * time.Second,
 			MaxConnectionAge:  maxConnectionAge * time.Minute,
 			Time:              gRPCTime * time.Minute,
 		}),
-	)
+	}
+	s := grpc.NewServer(append(serverOptions, opts...)...)
 
 	return &GrpcServer{
 		Grpc:   s,
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/discovery"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"gorm.io/gorm"
+)
+
+// NewGormChecker ping the database of db, e.g. from gormpg.New, name tells databases apart, e.g. postgres
+func NewGormChecker(name string, db *gorm.DB) Checker {
+	return NewChecker(name, func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// NewRedisChecker ping redis, e.g. the client from redis.NewRedisClient, name tells clients apart, e.g. redis
+func NewRedisChecker(name string, client redis.UniversalClient) Checker {
+	return NewChecker(name, func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	})
+}
+
+// NewRegisterChecker check etcd is reachable and the service registration is alive, e.g. named etcd
+func NewRegisterChecker(name string, register *discovery.Register) Checker {
+	return NewChecker(name, register.Check)
+}
+
+// NewGrpcConnChecker check conn is or becomes ready, an idle connection is asked to connect
+func NewGrpcConnChecker(name string, conn *grpc.ClientConn) Checker {
+	return NewChecker(name, func(ctx context.Context) error {
+		for {
+			state := conn.GetState()
+			switch state {
+			case connectivity.Ready:
+				return nil
+			case connectivity.Shutdown:
+				return errors.New("grpc connection is closed")
+			case connectivity.Idle:
+				conn.Connect()
+			}
+			if !conn.WaitForStateChange(ctx, state) {
+				return errors.WrapIf(ctx.Err(), fmt.Sprintf("grpc connection is %s", state))
+			}
+		}
+	})
+}
@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+type HealthOptions struct {
+	// Timeout of every check
+	Timeout time.Duration `mapstructure:"timeout"`
+	// CacheTTL how long a check result is reused, so probes do not hammer the dependencies
+	CacheTTL      time.Duration `mapstructure:"cacheTTL"`
+	LivenessPath  string        `mapstructure:"livenessPath"`
+	ReadinessPath string        `mapstructure:"readinessPath"`
+	// WatchInterval how often the grpc health Watch stream re-evaluates the status
+	WatchInterval time.Duration `mapstructure:"watchInterval"`
+}
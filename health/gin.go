@@ -0,0 +1,42 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	httpserver "github.com/go-thread-7/commonlib/http/http-server"
+)
+
+// Mount register the liveness and readiness endpoints on the server root, readiness also fails
+// while the server lifecycle is not ready, e.g. during shutdown
+func (h *Health) Mount(server *httpserver.HttpServer) error {
+	if err := h.AddReadinessGate("server", server.Lifecycle.Ready); err != nil {
+		return err
+	}
+	server.Engine.GET(h.options.LivenessPath, h.LivenessHandler())
+	server.Engine.GET(h.options.ReadinessPath, h.ReadinessHandler())
+	return nil
+}
+
+// LivenessHandler respond the liveness report, 503 when a check is down
+func (h *Health) LivenessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeReport(c, h.Liveness(c.Request.Context()))
+	}
+}
+
+// ReadinessHandler respond the readiness report, 503 when a check is down
+func (h *Health) ReadinessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeReport(c, h.Readiness(c.Request.Context()))
+	}
+}
+
+func writeReport(c *gin.Context, report *Report) {
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// grpcHealthServer grpc health service, the empty service name reports readiness,
+// other service names report the checker of that name
+type grpcHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	health *Health
+}
+
+// RegisterGrpc register h as the grpc health service of server
+func (h *Health) RegisterGrpc(server *grpc.Server) {
+	healthpb.RegisterHealthServer(server, &grpcHealthServer{health: h})
+}
+
+func (s *grpcHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	servingStatus, err := s.status(ctx, req.GetService())
+	if err != nil {
+		return nil, err
+	}
+	return &healthpb.HealthCheckResponse{Status: servingStatus}, nil
+}
+
+// Watch send the status on start and on every change, evaluated every WatchInterval
+func (s *grpcHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ticker := time.NewTicker(s.health.options.WatchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		servingStatus, err := s.status(stream.Context(), req.GetService())
+		if status.Code(err) == codes.NotFound {
+			servingStatus = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		} else if err != nil {
+			return err
+		}
+		if servingStatus != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: servingStatus}); err != nil {
+				return err
+			}
+			last = servingStatus
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *grpcHealthServer) status(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	if service == "" {
+		return servingStatus(s.health.Readiness(ctx).Status), nil
+	}
+
+	result, ok := s.health.CheckByName(ctx, service)
+	if !ok {
+		return healthpb.HealthCheckResponse_UNKNOWN, status.Errorf(codes.NotFound, "unknown service %s", service)
+	}
+	return servingStatus(result.Status), nil
+}
+
+func servingStatus(s Status) healthpb.HealthCheckResponse_ServingStatus {
+	if s == StatusUp {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
@@ -0,0 +1,290 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/health/config"
+)
+
+const (
+	defaultTimeout       = 2 * time.Second
+	defaultCacheTTL      = time.Second
+	defaultLivenessPath  = "/health/live"
+	defaultReadinessPath = "/health/ready"
+	defaultWatchInterval = 5 * time.Second
+)
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Checker check a dependency, a nil error means healthy
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c *checkerFunc) Name() string {
+	return c.name
+}
+
+func (c *checkerFunc) Check(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+// NewChecker create checker from a check function
+func NewChecker(name string, check func(ctx context.Context) error) Checker {
+	return &checkerFunc{name: name, check: check}
+}
+
+// CheckResult result of one check in the json breakdown
+type CheckResult struct {
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Report overall status, down when any check is down, and the result of every check
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type gate struct {
+	name  string
+	ready func() bool
+}
+
+// Health liveness and readiness checks, liveness should only cover the process itself
+// so that a failing dependency does not restart every replica
+type Health struct {
+	options config.HealthOptions
+
+	mu        sync.Mutex
+	liveness  []Checker
+	readiness []Checker
+	gates     []gate
+	cache     map[string]CheckResult
+}
+
+func New(options *config.HealthOptions) *Health {
+	return &Health{options: withDefaults(options), cache: map[string]CheckResult{}}
+}
+
+func withDefaults(options *config.HealthOptions) config.HealthOptions {
+	o := config.HealthOptions{}
+	if options != nil {
+		o = *options
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = defaultCacheTTL
+	}
+	if o.LivenessPath == "" {
+		o.LivenessPath = defaultLivenessPath
+	}
+	if o.ReadinessPath == "" {
+		o.ReadinessPath = defaultReadinessPath
+	}
+	if o.WatchInterval <= 0 {
+		o.WatchInterval = defaultWatchInterval
+	}
+	return o
+}
+
+// AddLivenessCheck add liveness checkers, names must be unique among the checks and gates
+func (h *Health) AddLivenessCheck(checkers ...Checker) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.checkNames(checkers); err != nil {
+		return err
+	}
+	h.liveness = append(h.liveness, checkers...)
+	return nil
+}
+
+// AddReadinessCheck add readiness checkers, names must be unique among the checks and gates
+func (h *Health) AddReadinessCheck(checkers ...Checker) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.checkNames(checkers); err != nil {
+		return err
+	}
+	h.readiness = append(h.readiness, checkers...)
+	return nil
+}
+
+// AddReadinessGate add a readiness condition evaluated on every check without timeout or caching,
+// e.g. the lifecycle of the http server failing readiness on shutdown, names must be unique among the checks and gates
+func (h *Health) AddReadinessGate(name string, ready func() bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.registered(name) {
+		return errors.Errorf("health check %s is already registered", name)
+	}
+	h.gates = append(h.gates, gate{name: name, ready: ready})
+	return nil
+}
+
+// checkNames reject checkers whose name is taken, results are cached and reported by name
+// so that two checks of the same name would hide each other
+func (h *Health) checkNames(checkers []Checker) error {
+	seen := map[string]bool{}
+	for _, checker := range checkers {
+		name := checker.Name()
+		if seen[name] || h.registered(name) {
+			return errors.Errorf("health check %s is already registered", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func (h *Health) registered(name string) bool {
+	if contains(h.liveness, name) || contains(h.readiness, name) {
+		return true
+	}
+	for _, g := range h.gates {
+		if g.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(checkers []Checker, name string) bool {
+	for _, checker := range checkers {
+		if checker.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Health) Liveness(ctx context.Context) *Report {
+	h.mu.Lock()
+	checkers := append([]Checker(nil), h.liveness...)
+	h.mu.Unlock()
+
+	return h.run(ctx, checkers, nil)
+}
+
+func (h *Health) Readiness(ctx context.Context) *Report {
+	h.mu.Lock()
+	checkers := append([]Checker(nil), h.readiness...)
+	gates := append([]gate(nil), h.gates...)
+	h.mu.Unlock()
+
+	return h.run(ctx, checkers, gates)
+}
+
+// CheckByName result of the liveness or readiness checker named name, false when there is none
+func (h *Health) CheckByName(ctx context.Context, name string) (CheckResult, bool) {
+	h.mu.Lock()
+	var checker Checker
+	for _, c := range append(append([]Checker(nil), h.readiness...), h.liveness...) {
+		if c.Name() == name {
+			checker = c
+			break
+		}
+	}
+	h.mu.Unlock()
+
+	if checker == nil {
+		return CheckResult{}, false
+	}
+	return h.check(ctx, checker), true
+}
+
+// run run checkers concurrently, then evaluate gates
+func (h *Health) run(ctx context.Context, checkers []Checker, gates []gate) *Report {
+	report := &Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(checkers)+len(gates))}
+
+	results := make([]CheckResult, len(checkers))
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
+			results[i] = h.check(ctx, checker)
+		}(i, checker)
+	}
+	wg.Wait()
+
+	for i, checker := range checkers {
+		report.Checks[checker.Name()] = results[i]
+	}
+	for _, g := range gates {
+		result := CheckResult{Status: StatusUp, Duration: "0s", CheckedAt: time.Now()}
+		if !g.ready() {
+			result.Status = StatusDown
+			result.Error = "not ready"
+		}
+		report.Checks[g.name] = result
+	}
+
+	for _, result := range report.Checks {
+		if result.Status == StatusDown {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// check run checker with the check timeout, or return its result cached for CacheTTL
+func (h *Health) check(ctx context.Context, checker Checker) CheckResult {
+	h.mu.Lock()
+	cached, ok := h.cache[checker.Name()]
+	h.mu.Unlock()
+	if ok && time.Since(cached.CheckedAt) < h.options.CacheTTL {
+		return cached
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, h.options.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checkWithContext(checkCtx, checker)
+	result := CheckResult{Status: StatusUp, Duration: time.Since(start).Round(time.Microsecond).String(), CheckedAt: start}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	// a result of a canceled probe says nothing about the dependency
+	if ctx.Err() == nil {
+		h.mu.Lock()
+		h.cache[checker.Name()] = result
+		h.mu.Unlock()
+	}
+	return result
+}
+
+// checkWithContext return when ctx is done even if the checker ignores ctx
+func checkWithContext(ctx context.Context, checker Checker) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
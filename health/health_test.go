@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/health/config"
+)
+
+func TestDuplicateNames(t *testing.T) {
+	up := func(context.Context) error { return nil }
+
+	h := New(nil)
+	if err := h.AddReadinessCheck(NewChecker("postgres", up)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddLivenessCheck(NewChecker("process", up)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddReadinessGate("server", func() bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		add  func() error
+	}{
+		{name: "readiness", add: func() error { return h.AddReadinessCheck(NewChecker("postgres", up)) }},
+		{name: "liveness over readiness", add: func() error { return h.AddLivenessCheck(NewChecker("postgres", up)) }},
+		{name: "readiness over liveness", add: func() error { return h.AddReadinessCheck(NewChecker("process", up)) }},
+		{name: "same call", add: func() error { return h.AddReadinessCheck(NewChecker("redis", up), NewChecker("redis", up)) }},
+		{name: "checker over gate", add: func() error { return h.AddReadinessCheck(NewChecker("server", up)) }},
+		{name: "gate over checker", add: func() error { return h.AddReadinessGate("postgres", func() bool { return true }) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.add(); err == nil {
+				t.Fatal("duplicate name registered")
+			}
+		})
+	}
+
+	// a rejected call registers none of its checkers
+	if _, ok := h.CheckByName(context.Background(), "redis"); ok {
+		t.Fatal("checker of a rejected call registered")
+	}
+	if err := h.AddReadinessCheck(NewChecker("postgres-replica", up)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	failure := errors.New("connection refused")
+	ready := true
+
+	h := New(&config.HealthOptions{Timeout: 50 * time.Millisecond, CacheTTL: time.Hour})
+	calls := 0
+	checkers := []Checker{
+		NewChecker("postgres", func(context.Context) error { calls++; return nil }),
+		NewChecker("redis", func(context.Context) error { return failure }),
+		// ignores its context, the check times out anyway
+		NewChecker("etcd", func(context.Context) error { time.Sleep(time.Second); return nil }),
+	}
+	if err := h.AddReadinessCheck(checkers...); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddReadinessGate("server", func() bool { return ready }); err != nil {
+		t.Fatal(err)
+	}
+
+	report := h.Readiness(context.Background())
+	if report.Status != StatusDown {
+		t.Fatalf("status = %s, want down", report.Status)
+	}
+	want := map[string]Status{"postgres": StatusUp, "redis": StatusDown, "etcd": StatusDown, "server": StatusUp}
+	for name, status := range want {
+		if got := report.Checks[name].Status; got != status {
+			t.Fatalf("check %s = %s, want %s", name, got, status)
+		}
+	}
+	if got := report.Checks["redis"].Error; got != failure.Error() {
+		t.Fatalf("redis error = %q", got)
+	}
+
+	// results are cached, gates are evaluated every time
+	ready = false
+	report = h.Readiness(context.Background())
+	if calls != 1 {
+		t.Fatalf("postgres checked %d times, want 1", calls)
+	}
+	if report.Checks["server"].Status != StatusDown {
+		t.Fatal("gate not evaluated")
+	}
+}
@@ -1,11 +1,53 @@
 package config
 
+import "time"
+
 type HTTPConfig struct {
-	Port                string   `mapstructure:"port" validate:"required"`
-	Development         bool     `mapstructure:"development"`
-	BasePath            string   `mapstructure:"basePath" validate:"required"`
+	Port        string `mapstructure:"port" validate:"required"`
+	Development bool   `mapstructure:"development"`
+	BasePath    string `mapstructure:"basePath" validate:"required"`
+	// DebugErrorsResponse write the stack trace of errors and panics in problem details responses
 	DebugErrorsResponse bool     `mapstructure:"debugErrorsResponse"`
 	IgnoreLogUrls       []string `mapstructure:"ignoreLogUrls"`
-	Timeout             int      `mapstructure:"timeout"`
-	Host                string   `mapstructure:"host"`
+	// TrustedProxies ips or cidrs of the proxies whose X-Forwarded-For gives the client ip, none by default
+	TrustedProxies []string `mapstructure:"trustedProxies"`
+	// Timeout per-request timeout in seconds, 0 disables it
+	Timeout int    `mapstructure:"timeout"`
+	Host    string `mapstructure:"host"`
+	// server timeouts, zero values use the httpserver defaults
+	ReadTimeout       time.Duration `mapstructure:"readTimeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `mapstructure:"writeTimeout"`
+	IdleTimeout       time.Duration `mapstructure:"idleTimeout"`
+	MaxHeaderBytes    int           `mapstructure:"maxHeaderBytes"`
+	// PreStopDelay time readiness fails before draining, so load balancers stop routing new requests
+	PreStopDelay time.Duration `mapstructure:"preStopDelay"`
+	// ShutdownTimeout time to drain in-flight requests, and separately to run the shutdown hooks
+	ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout"`
+	// TLS serve https when CertFile and KeyFile are set
+	TLS TLSConfig `mapstructure:"tls"`
+	// H2C serve HTTP/2 without TLS, with TLS HTTP/2 is negotiated anyway
+	H2C bool `mapstructure:"h2c"`
+	// Versioning how the api version of a request is resolved, see httpserver.NewVersioning
+	Versioning VersioningConfig `mapstructure:"versioning"`
+}
+
+type VersioningConfig struct {
+	// Strategy header, query, mediaType or urlSegment, default header
+	Strategy string `mapstructure:"strategy"`
+	// Name of the header, query parameter or media type parameter carrying the version, default version
+	Name string `mapstructure:"name"`
+	// DefaultVersion used when the request has no version, requests without version are rejected when empty
+	DefaultVersion string `mapstructure:"defaultVersion"`
+}
+
+type TLSConfig struct {
+	CertFile string `mapstructure:"certFile"`
+	KeyFile  string `mapstructure:"keyFile"`
+	// ClientCAFile enable mTLS, client certificates are verified against these CAs
+	ClientCAFile string `mapstructure:"clientCAFile"`
+	// ClientAuth none, request, require, verifyIfGiven or requireAndVerify, default requireAndVerify with ClientCAFile
+	ClientAuth string `mapstructure:"clientAuth"`
+	// ReloadInterval how often changed certificate files are reloaded, default 1 minute
+	ReloadInterval time.Duration `mapstructure:"reloadInterval"`
 }
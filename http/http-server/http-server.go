@@ -4,53 +4,206 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
+	"emperror.dev/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-thread-7/commonlib/http/http-server/config"
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const (
-	MaxHeaderBytes = 1 << 20
-	ReadTimeout    = 15 * time.Second
-	WriteTimeout   = 15 * time.Second
+	MaxHeaderBytes    = 1 << 20
+	ReadTimeout       = 15 * time.Second
+	ReadHeaderTimeout = 5 * time.Second
+	WriteTimeout      = 15 * time.Second
+	IdleTimeout       = 60 * time.Second
+	ShutdownTimeout   = 15 * time.Second
 )
 
+// HttpServer gin engine with the default middleware stack, routes are registered on Routes under config.BasePath
+type HttpServer struct {
+	Engine    *gin.Engine
+	Routes    *gin.RouterGroup
+	Config    *config.HTTPConfig
+	Lifecycle *Lifecycle
+
+	wrappers []func(http.Handler) http.Handler
+}
+
 func New() *gin.Engine {
 	router := gin.New()
 	return router
 }
 
+// NewServer create engine with request id, access log, problem details with panic recovery and request timeout middleware,
+// middlewares are added after them, X-Forwarded-For is only trusted from config.TrustedProxies, invalid ones panic
+func NewServer(cfg *config.HTTPConfig, middlewares ...gin.HandlerFunc) *HttpServer {
+	router := gin.New()
+	// the client ip is the remote address unless the request comes from a trusted proxy
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("invalid trusted proxies %v: %v", cfg.TrustedProxies, err))
+	}
+	router.Use(
+		RequestID(),
+		AccessLog(cfg.IgnoreLogUrls),
+		// recovers panics of the next handlers and writes them as problem details
+		problemdetail.GinMiddleware(cfg.DebugErrorsResponse),
+		Timeout(time.Duration(cfg.Timeout)*time.Second),
+	)
+	router.Use(middlewares...)
+
+	return &HttpServer{
+		Engine:    router,
+		Routes:    router.Group(cfg.BasePath),
+		Config:    cfg,
+		Lifecycle: NewLifecycle(),
+	}
+}
+
+// WrapHandler wrap the engine in a handler running before gin routes the request, e.g. to rewrite the path
+func (s *HttpServer) WrapHandler(wrap func(next http.Handler) http.Handler) {
+	s.wrappers = append(s.wrappers, wrap)
+}
+
+// Handler engine wrapped by the handlers of WrapHandler, the last added runs first
+func (s *HttpServer) Handler() http.Handler {
+	var handler http.Handler = s.Engine
+	for _, wrap := range s.wrappers {
+		handler = wrap(handler)
+	}
+	return handler
+}
+
+// RunHttpServer serve until ctx is canceled, then shut down gracefully: readiness of the lifecycle fails, the server
+// waits config.PreStopDelay, drains in-flight requests for up to config.ShutdownTimeout, closes the rest and runs
+// the shutdown hooks, which also run when serving fails, a nil lifecycle is created
+func (s *HttpServer) RunHttpServer(ctx context.Context) error {
+	if s.Lifecycle == nil {
+		s.Lifecycle = NewLifecycle()
+	}
+	return runHttpServer(ctx, s.Handler(), s.Config, s.Lifecycle)
+}
+
+// RunHttpServer serve until ctx is canceled, then shut down gracefully: the server waits config.PreStopDelay,
+// drains in-flight requests for up to config.ShutdownTimeout and closes the rest.
+//
+// Deprecated: the readiness and shutdown hooks of the lifecycle are not reachable, use NewServer and
+// (*HttpServer).RunHttpServer, or for an existing engine &HttpServer{Engine: router, Config: cfg, Lifecycle: lifecycle}
 func RunHttpServer(ctx context.Context, router *gin.Engine, cfg *config.HTTPConfig) error {
-	server := &http.Server{
-		Addr:           fmt.Sprintf(":%s", cfg.Port),
-		Handler:        router,
-		ReadTimeout:    ReadTimeout,
-		WriteTimeout:   WriteTimeout,
-		MaxHeaderBytes: MaxHeaderBytes,
+	return runHttpServer(ctx, router, cfg, NewLifecycle())
+}
+
+func runHttpServer(ctx context.Context, handler http.Handler, cfg *config.HTTPConfig, lifecycle *Lifecycle) error {
+	server, err := newServer(handler, cfg)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return errors.Wrap(err, "net.Listen")
 	}
+	return serveListener(ctx, server, listener, cfg, lifecycle)
+}
+
+// serveListener run server on listener until ctx is canceled or serving fails, the shutdown hooks run in both cases
+func serveListener(ctx context.Context, server *http.Server, listener net.Listener, cfg *config.HTTPConfig, lifecycle *Lifecycle) error {
+	// Serve writes the tls config, it is read before
+	tlsOn := server.TLSConfig != nil
+	serveErr := make(chan error, 1)
 	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				log.Printf("shutting down HTTP port: %s\n", cfg.Port)
-				err := server.Shutdown(ctx)
-				if err != nil {
-					log.Printf("(Shutdown) err: %v\n", err)
-					return
-				}
-				log.Println("server exited properly")
-				return
-			}
+		if tlsOn {
+			serveErr <- server.ServeTLS(listener, "", "")
+			return
 		}
+		serveErr <- server.Serve(listener)
 	}()
+	lifecycle.SetReady(true)
+	log.Printf("starting up HTTP server on %s, tls: %t, h2c: %t\n", listener.Addr(), tlsOn, !tlsOn && cfg.H2C)
 
-	log.Printf("starting up HTTP server on port: %s\n", cfg.Port)
-	err := server.ListenAndServe()
-	return err
+	select {
+	case err := <-serveErr:
+		lifecycle.SetReady(false)
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		if err != nil {
+			log.Printf("HTTP server on %s failed: %v\n", listener.Addr(), err)
+		}
+		return errors.Combine(err, lifecycle.runHooks(orDefault(cfg.ShutdownTimeout, ShutdownTimeout)))
+	case <-ctx.Done():
+	}
+
+	return shutdown(server, cfg, lifecycle, serveErr)
+}
+
+func shutdown(server *http.Server, cfg *config.HTTPConfig, lifecycle *Lifecycle, serveErr <-chan error) error {
+	lifecycle.SetReady(false)
+	log.Printf("shutting down HTTP server on %s\n", server.Addr)
+
+	if cfg.PreStopDelay > 0 {
+		log.Printf("readiness failing, waiting %s before draining\n", cfg.PreStopDelay)
+		time.Sleep(cfg.PreStopDelay)
+	}
+
+	timeout := orDefault(cfg.ShutdownTimeout, ShutdownTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := server.Shutdown(drainCtx); err != nil {
+		log.Printf("(Shutdown) drain err: %v, closing remaining connections\n", err)
+		errs = append(errs, errors.Wrap(err, "server.Shutdown"), server.Close())
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	errs = append(errs, lifecycle.runHooks(timeout))
+
+	if err := errors.Combine(errs...); err != nil {
+		return err
+	}
+	log.Println("server exited properly")
+	return nil
+}
+
+// newServer http server listening on config.Host and config.Port, zero timeouts use the defaults,
+// serving tls when configured, h2c otherwise when enabled
+func newServer(handler http.Handler, cfg *config.HTTPConfig) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              net.JoinHostPort(cfg.Host, cfg.Port),
+		Handler:           handler,
+		ReadTimeout:       orDefault(cfg.ReadTimeout, ReadTimeout),
+		ReadHeaderTimeout: orDefault(cfg.ReadHeaderTimeout, ReadHeaderTimeout),
+		WriteTimeout:      orDefault(cfg.WriteTimeout, WriteTimeout),
+		IdleTimeout:       orDefault(cfg.IdleTimeout, IdleTimeout),
+		MaxHeaderBytes:    orDefault(cfg.MaxHeaderBytes, MaxHeaderBytes),
+	}
+
+	if tlsEnabled(&cfg.TLS) {
+		tlsConfig, err := newTLSConfig(&cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = tlsConfig
+	} else if cfg.H2C {
+		server.Handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: server.IdleTimeout})
+	}
+	return server, nil
+}
+
+func orDefault[T time.Duration | int](value T, def T) T {
+	if value <= 0 {
+		return def
+	}
+	return value
 }
 
+// Deprecated: the path is rewritten after gin matched the route, use NewVersioning
 func ApplyVersioningFromHeader(router *gin.Engine) {
 	router.Use(apiVersion)
 }
@@ -0,0 +1,131 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-thread-7/commonlib/http/http-server/config"
+)
+
+func TestRunHttpServerLifecycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	lifecycle := NewLifecycle()
+	server := &HttpServer{Engine: gin.New(), Config: &config.HTTPConfig{Host: "127.0.0.1", Port: "0"}, Lifecycle: lifecycle}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.RunHttpServer(ctx) }()
+
+	waitFor(t, lifecycle.Ready)
+	hookRan := make(chan struct{})
+	lifecycle.OnShutdown("test", func(context.Context) error {
+		close(hookRan)
+		return nil
+	})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-hookRan:
+	default:
+		t.Fatal("shutdown hook did not run")
+	}
+	if lifecycle.Ready() {
+		t.Fatal("lifecycle still ready after shutdown")
+	}
+}
+
+// failingListener listener whose Accept fails, e.g. after the socket was closed underneath the server
+type failingListener struct {
+	net.Listener
+}
+
+func (l failingListener) Accept() (net.Conn, error) {
+	return nil, errors.New("accept failed")
+}
+
+func TestServeErrorRunsShutdownHooks(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	lifecycle := NewLifecycle()
+	hookRan := false
+	lifecycle.OnShutdown("test", func(context.Context) error {
+		hookRan = true
+		return nil
+	})
+
+	cfg := &config.HTTPConfig{}
+	err = serveListener(context.Background(), &http.Server{Handler: gin.New()}, failingListener{listener}, cfg, lifecycle)
+	if err == nil || !strings.Contains(err.Error(), "accept failed") {
+		t.Fatalf("serveListener() error = %v, want the accept error", err)
+	}
+	if !hookRan {
+		t.Fatal("shutdown hook did not run")
+	}
+	if lifecycle.Ready() {
+		t.Fatal("lifecycle still ready after the server failed")
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNewServerTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		want           string
+	}{
+		{name: "no trusted proxy", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "untrusted proxy", trustedProxies: []string{"10.0.1.0/24"}, remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "trusted proxy", trustedProxies: []string{"10.0.1.0/24"}, remoteAddr: "10.0.1.5:1234", want: "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(&config.HTTPConfig{BasePath: "/", TrustedProxies: tt.trustedProxies})
+			var clientIP string
+			server.Routes.GET("/", func(c *gin.Context) { clientIP = c.ClientIP() })
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.Header.Set("X-Forwarded-For", "203.0.113.7")
+			server.Handler().ServeHTTP(httptest.NewRecorder(), r)
+			if clientIP != tt.want {
+				t.Fatalf("client ip = %s, want %s", clientIP, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewServerInvalidTrustedProxies(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewServer() did not panic")
+		}
+	}()
+	NewServer(&config.HTTPConfig{BasePath: "/", TrustedProxies: []string{"not-an-ip"}})
+}
@@ -0,0 +1,78 @@
+package httpserver
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// ShutdownHook release a resource after the server drained, e.g. close database connections
+type ShutdownHook func(ctx context.Context) error
+
+// Lifecycle readiness of the server and hooks run on shutdown
+type Lifecycle struct {
+	ready atomic.Bool
+	mu    sync.Mutex
+	hooks []namedHook
+}
+
+type namedHook struct {
+	name string
+	hook ShutdownHook
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Ready true while the server is listening and not shutting down
+func (l *Lifecycle) Ready() bool {
+	return l.ready.Load()
+}
+
+func (l *Lifecycle) SetReady(ready bool) {
+	l.ready.Store(ready)
+}
+
+// OnShutdown register hook run after the server drained, hooks run in reverse registration order
+func (l *Lifecycle) OnShutdown(name string, hook ShutdownHook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, namedHook{name: name, hook: hook})
+}
+
+// ReadinessHandler respond 200 while ready and 503 otherwise
+func (l *Lifecycle) ReadinessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.Ready() {
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+}
+
+// runHooks run every hook with a context canceled after timeout, errors are logged and combined
+func (l *Lifecycle) runHooks(timeout time.Duration) error {
+	l.mu.Lock()
+	hooks := append([]namedHook(nil), l.hooks...)
+	l.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].hook(ctx); err != nil {
+			log.Printf("shutdown hook %s err: %v\n", hooks[i].name, err)
+			errs = append(errs, errors.WrapIf(err, "shutdown hook "+hooks[i].name))
+		}
+	}
+	return errors.Combine(errs...)
+}
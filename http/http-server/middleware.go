@@ -0,0 +1,115 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext request id set by the RequestID middleware
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID propagate the X-Request-ID header of the request, or a generated id,
+// to the response header and the request context
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDKey{}, id))
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AccessLog log every request as a structured record with slog, except the paths of ignoreUrls,
+// an entry ending with * matches paths starting with it
+func AccessLog(ignoreUrls []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if ignored(path, ignoreUrls) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", path,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"size", c.Writer.Size(),
+			"latency", time.Since(start),
+			"client_ip", c.ClientIP(),
+			"request_id", RequestIDFromContext(c.Request.Context()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", strings.Join(c.Errors.Errors(), "; "))
+		}
+
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			slog.Error("http request", attrs...)
+		case status >= http.StatusBadRequest:
+			slog.Warn("http request", attrs...)
+		default:
+			slog.Info("http request", attrs...)
+		}
+	}
+}
+
+func ignored(path string, ignoreUrls []string) bool {
+	for _, url := range ignoreUrls {
+		if prefix, ok := strings.CutSuffix(url, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == url {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeout cancel the request context after timeout and respond 503 when the handler returned
+// without writing a response, handlers must honour the request context to stop early
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if ctx.Err() == context.DeadlineExceeded && !c.Writer.Written() {
+			c.AbortWithStatus(http.StatusServiceUnavailable)
+		}
+	}
+}
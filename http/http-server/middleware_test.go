@@ -0,0 +1,198 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		incoming string
+	}{
+		{name: "propagated", incoming: "req-1"},
+		{name: "generated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext string
+			router := gin.New()
+			router.Use(RequestID())
+			router.GET("/", func(c *gin.Context) {
+				fromContext = RequestIDFromContext(c.Request.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.incoming != "" && id != tt.incoming {
+				t.Fatalf("request id = %q, want %q", id, tt.incoming)
+			}
+			if tt.incoming == "" && !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(id) {
+				t.Fatalf("generated request id = %q", id)
+			}
+			if fromContext != id {
+				t.Fatalf("request id of the context = %q, want %q", fromContext, id)
+			}
+		})
+	}
+}
+
+// captureLog decode the records logged with the default slog logger during the test
+func captureLog(t *testing.T) func() []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return func() []map[string]any {
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			record := map[string]any{}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		path      string
+		status    int
+		wantLevel string
+		ignored   bool
+	}{
+		{name: "success", path: "/orders/1", status: http.StatusOK, wantLevel: "INFO"},
+		{name: "client error", path: "/orders/1", status: http.StatusNotFound, wantLevel: "WARN"},
+		{name: "server error", path: "/orders/1", status: http.StatusInternalServerError, wantLevel: "ERROR"},
+		{name: "ignored path", path: "/health", status: http.StatusOK, ignored: true},
+		{name: "ignored prefix", path: "/metrics/go", status: http.StatusOK, ignored: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := captureLog(t)
+			router := gin.New()
+			router.Use(RequestID(), AccessLog([]string{"/health", "/metrics/*"}))
+			handler := func(c *gin.Context) { c.Status(tt.status) }
+			router.GET("/orders/:id", handler)
+			router.GET("/health", handler)
+			router.GET("/metrics/go", handler)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			logged := records()
+			if tt.ignored {
+				if len(logged) != 0 {
+					t.Fatalf("ignored path logged: %v", logged)
+				}
+				return
+			}
+			if len(logged) != 1 {
+				t.Fatalf("records = %v, want one", logged)
+			}
+			record := logged[0]
+			want := map[string]any{
+				"level":      tt.wantLevel,
+				"method":     http.MethodGet,
+				"path":       tt.path,
+				"route":      "/orders/:id",
+				"status":     float64(tt.status),
+				"request_id": "req-1",
+			}
+			for key, value := range want {
+				if record[key] != value {
+					t.Errorf("%s = %v, want %v", key, record[key], value)
+				}
+			}
+			if _, ok := record["latency"]; !ok {
+				t.Error("latency not logged")
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		handler gin.HandlerFunc
+		status  int
+	}{
+		{
+			name:    "handler honouring the deadline",
+			timeout: 10 * time.Millisecond,
+			handler: func(c *gin.Context) { <-c.Request.Context().Done() },
+			status:  http.StatusServiceUnavailable,
+		},
+		{
+			name:    "response written in time",
+			timeout: time.Second,
+			handler: func(c *gin.Context) { c.Status(http.StatusNoContent) },
+			status:  http.StatusNoContent,
+		},
+		{
+			name:    "response written after the deadline",
+			timeout: 10 * time.Millisecond,
+			handler: func(c *gin.Context) {
+				<-c.Request.Context().Done()
+				c.String(http.StatusAccepted, "accepted")
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			name:    "disabled",
+			timeout: 0,
+			handler: func(c *gin.Context) {
+				if _, ok := c.Request.Context().Deadline(); ok {
+					c.Status(http.StatusInternalServerError)
+					return
+				}
+				c.Status(http.StatusOK)
+			},
+			status: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Timeout(tt.timeout))
+			router.GET("/", tt.handler)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
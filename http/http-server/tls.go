@@ -0,0 +1,158 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/http/http-server/config"
+)
+
+const DefaultCertReloadInterval = time.Minute
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":             tls.NoClientCert,
+	"request":          tls.RequestClientCert,
+	"require":          tls.RequireAnyClientCert,
+	"verifyIfGiven":    tls.VerifyClientCertIfGiven,
+	"requireAndVerify": tls.RequireAndVerifyClientCert,
+}
+
+func tlsEnabled(cfg *config.TLSConfig) bool {
+	return cfg.CertFile != "" || cfg.KeyFile != ""
+}
+
+// newTLSConfig tls config serving the certificate of cfg, certificate and client CA files
+// are reloaded when they change, so rotated certificates are used without restart
+func newTLSConfig(cfg *config.TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("tls requires both certFile and keyFile")
+	}
+
+	clientAuth := tls.NoClientCert
+	if cfg.ClientCAFile != "" {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	if cfg.ClientAuth != "" {
+		var ok bool
+		if clientAuth, ok = clientAuthTypes[cfg.ClientAuth]; !ok {
+			return nil, errors.Errorf("invalid tls clientAuth %s", cfg.ClientAuth)
+		}
+	}
+	if clientAuth >= tls.VerifyClientCertIfGiven && cfg.ClientCAFile == "" {
+		return nil, errors.Errorf("tls clientAuth %s requires clientCAFile", cfg.ClientAuth)
+	}
+
+	reloader := &certReloader{config: cfg, interval: orDefault(cfg.ReloadInterval, DefaultCertReloadInterval)}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	// the config returned per client replaces the server config, so it carries the ALPN protocols for HTTP/2
+	nextProtos := []string{"h2", "http/1.1"}
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: clientAuth,
+		NextProtos: nextProtos,
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCAs := reloader.current()
+			c := base.Clone()
+			c.Certificates = []tls.Certificate{*cert}
+			c.ClientCAs = clientCAs
+			return c, nil
+		},
+	}, nil
+}
+
+// certReloader certificate and client CAs loaded from disk, reloaded at most once per interval
+// when the modification time of a file changed, a failed reload keeps the previous certificate
+type certReloader struct {
+	config   *config.TLSConfig
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  []time.Time
+	checkedAt time.Time
+}
+
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) >= r.interval {
+		r.checkedAt = time.Now()
+		if r.changed() {
+			if err := r.reload(); err != nil {
+				log.Printf("(TLS) reload certificate err: %v, keeping the previous certificate\n", err)
+			} else {
+				log.Printf("(TLS) reloaded certificate %s\n", r.config.CertFile)
+			}
+		}
+	}
+	return r.cert, r.clientCAs
+}
+
+func (r *certReloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkedAt = time.Now()
+	return r.reload()
+}
+
+func (r *certReloader) reload() error {
+	modTimes := r.fileModTimes()
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return errors.WrapIf(err, "failed to load tls certificate")
+	}
+
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return errors.WrapIf(err, "failed to read tls client CA file")
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.Errorf("no certificate found in tls client CA file %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.cert, r.clientCAs, r.modTimes = &cert, clientCAs, modTimes
+	return nil
+}
+
+func (r *certReloader) changed() bool {
+	modTimes := r.fileModTimes()
+	for i := range modTimes {
+		if !modTimes[i].Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) fileModTimes() []time.Time {
+	files := []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile}
+	modTimes := make([]time.Time, len(files))
+	for i, file := range files {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+	return modTimes
+}
@@ -0,0 +1,269 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+)
+
+const (
+	validateTag            = "validate"
+	defaultMultipartMemory = 32 << 20
+)
+
+var (
+	validation     *validator.Validate
+	translators    *ut.UniversalTranslator
+	validationOnce sync.Once
+	validationMu   sync.RWMutex
+)
+
+func initValidation() {
+	validationOnce.Do(func() {
+		validation = validator.New(validator.WithRequiredStructEnabled())
+		validation.SetTagName(validateTag)
+		validation.RegisterTagNameFunc(fieldName)
+
+		english := en.New()
+		translators = ut.New(english, english)
+		trans, _ := translators.GetTranslator(english.Locale())
+		if err := entranslations.RegisterDefaultTranslations(validation, trans); err != nil {
+			panic(err)
+		}
+	})
+}
+
+// Validator validator of the bind helpers using validate tags, e.g. to register custom rules and their translations,
+// registrations are not synchronized with validation, make them before serving requests
+func Validator() *validator.Validate {
+	initValidation()
+	return validation
+}
+
+// AddValidationLocale add translated validation messages, chosen by the Accept-Language header, e.g.
+// AddValidationLocale(fr.New(), frtranslations.RegisterDefaultTranslations)
+func AddValidationLocale(locale locales.Translator, register func(v *validator.Validate, trans ut.Translator) error) error {
+	initValidation()
+	validationMu.Lock()
+	defer validationMu.Unlock()
+
+	if err := translators.AddTranslator(locale, true); err != nil {
+		return err
+	}
+	trans, _ := translators.GetTranslator(locale.Locale())
+	return register(validation, trans)
+}
+
+// fieldName name of the field in the request, from the json, form or uri tag
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// BindBody decode the request body of T by content type, json, xml or form, and validate it,
+// other content types fail with 415
+func BindBody[T any](c *gin.Context) (*T, error) {
+	obj := new(T)
+	if err := decodeBody(c, obj); err != nil {
+		return nil, err
+	}
+	return obj, validate(c, obj)
+}
+
+// BindQuery decode the query parameters of T, by form tag, and validate it
+func BindQuery[T any](c *gin.Context) (*T, error) {
+	obj := new(T)
+	if err := mapForm(obj, c.Request.URL.Query(), "form"); err != nil {
+		return nil, err
+	}
+	return obj, validate(c, obj)
+}
+
+// BindUri decode the path parameters of T, by uri tag, and validate it
+func BindUri[T any](c *gin.Context) (*T, error) {
+	obj := new(T)
+	if err := mapForm(obj, uriParams(c), "uri"); err != nil {
+		return nil, err
+	}
+	return obj, validate(c, obj)
+}
+
+// Bind decode path parameters, query parameters and body into T, then validate it once,
+// errors are *problemdetail.ValidationError written as validation problem by problemdetail.GinMiddleware
+func Bind[T any](c *gin.Context) (*T, error) {
+	obj := new(T)
+	if err := mapForm(obj, uriParams(c), "uri"); err != nil {
+		return nil, err
+	}
+	if err := mapForm(obj, c.Request.URL.Query(), "form"); err != nil {
+		return nil, err
+	}
+	if err := decodeBody(c, obj); err != nil {
+		return nil, err
+	}
+	return obj, validate(c, obj)
+}
+
+// unsupportedMediaTypeError body of a content type the bind helpers cannot decode, written as problem details
+type unsupportedMediaTypeError struct {
+	contentType string
+}
+
+func (e *unsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("unsupported content type %s, supported: %s, %s, %s, %s", e.contentType,
+		binding.MIMEJSON, binding.MIMEXML, binding.MIMEPOSTForm, binding.MIMEMultipartPOSTForm)
+}
+
+func (e *unsupportedMediaTypeError) StatusCode() int {
+	return http.StatusUnsupportedMediaType
+}
+
+func uriParams(c *gin.Context) map[string][]string {
+	params := make(map[string][]string, len(c.Params))
+	for _, param := range c.Params {
+		params[param.Key] = []string{param.Value}
+	}
+	return params
+}
+
+func mapForm(obj any, form map[string][]string, tag string) error {
+	if err := binding.MapFormWithTag(obj, form, tag); err != nil {
+		return &problemdetail.ValidationError{Detail: fmt.Sprintf("invalid %s parameters: %v", tag, err)}
+	}
+	return nil
+}
+
+func decodeBody(c *gin.Context, obj any) error {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil
+	}
+
+	var err error
+	switch c.ContentType() {
+	case binding.MIMEJSON, "":
+		err = json.NewDecoder(c.Request.Body).Decode(obj)
+	case binding.MIMEXML, binding.MIMEXML2:
+		err = xml.NewDecoder(c.Request.Body).Decode(obj)
+	case binding.MIMEPOSTForm:
+		if err = c.Request.ParseForm(); err == nil {
+			return mapForm(obj, c.Request.PostForm, "form")
+		}
+	case binding.MIMEMultipartPOSTForm:
+		if err = c.Request.ParseMultipartForm(defaultMultipartMemory); err == nil {
+			return mapForm(obj, c.Request.MultipartForm.Value, "form")
+		}
+	default:
+		return &unsupportedMediaTypeError{contentType: c.ContentType()}
+	}
+
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := jsonFieldPath(typeErr.Field)
+		return &problemdetail.ValidationError{Errors: []problemdetail.FieldError{{
+			Field:   field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be a %s", field, typeErr.Type),
+		}}}
+	}
+	if err != nil {
+		return &problemdetail.ValidationError{Detail: fmt.Sprintf("malformed request body: %v", err)}
+	}
+	return nil
+}
+
+// jsonFieldPath field of a json decoding error in the notation of the validation errors, e.g. items.0.name as items[0].name
+func jsonFieldPath(field string) string {
+	var b strings.Builder
+	for i, segment := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(segment); err == nil && i > 0 {
+			b.WriteString("[" + segment + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(segment)
+	}
+	return b.String()
+}
+
+// validate validate obj by validate tags, messages are translated to the Accept-Language of the request
+func validate(c *gin.Context, obj any) error {
+	initValidation()
+
+	// AddValidationLocale registers rules and translations on the shared validator
+	validationMu.RLock()
+	defer validationMu.RUnlock()
+
+	err := validation.Struct(obj)
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	trans := translator(c.GetHeader("Accept-Language"))
+	fieldErrors := make([]problemdetail.FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		fieldErrors = append(fieldErrors, problemdetail.FieldError{
+			Field:   fieldPath(fieldError),
+			Rule:    fieldError.Tag(),
+			Message: fieldError.Translate(trans),
+		})
+	}
+	return &problemdetail.ValidationError{Errors: fieldErrors}
+}
+
+// fieldPath namespace of the field without the struct name, e.g. items[0].name
+func fieldPath(fieldError validator.FieldError) string {
+	_, path, found := strings.Cut(fieldError.Namespace(), ".")
+	if !found {
+		return fieldError.Field()
+	}
+	return path
+}
+
+// translator translator of the first supported language of acceptLanguage, english otherwise, validationMu must be held
+func translator(acceptLanguage string) ut.Translator {
+	tags := []string{}
+	for _, language := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(language), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		tag = strings.ReplaceAll(tag, "-", "_")
+		tags = append(tags, tag)
+		if base, _, ok := strings.Cut(tag, "_"); ok {
+			tags = append(tags, base)
+		}
+	}
+
+	trans, _ := translators.FindTranslator(tags...)
+	return trans
+}
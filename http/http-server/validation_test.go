@@ -0,0 +1,134 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/fr"
+	frtranslations "github.com/go-playground/validator/v10/translations/fr"
+	"github.com/go-thread-7/commonlib/http/http-server/config"
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+)
+
+type orderItem struct {
+	Name     string `json:"name" form:"name" validate:"required"`
+	Quantity int    `json:"quantity" form:"quantity" validate:"gte=1"`
+}
+
+type createOrder struct {
+	ID    int64       `uri:"id" validate:"gte=1"`
+	Email string      `json:"email" form:"email" validate:"required,email"`
+	Items []orderItem `json:"items" validate:"dive"`
+}
+
+func TestBindProblems(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewServer(&config.HTTPConfig{BasePath: "/"})
+	server.Routes.POST("/orders/:id", func(c *gin.Context) {
+		order, err := Bind[createOrder](c)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, order)
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		errors      []problemdetail.FieldError
+	}{
+		{name: "valid", path: "/orders/1", contentType: "application/json", body: `{"email":"a@b.io","items":[{"name":"pen","quantity":2}]}`, status: 200},
+		{name: "valid form", path: "/orders/1", contentType: "application/x-www-form-urlencoded", body: "email=a%40b.io", status: 200},
+		{
+			name:        "invalid fields",
+			path:        "/orders/0",
+			contentType: "application/json",
+			body:        `{"email":"nope","items":[{"name":"","quantity":1}]}`,
+			status:      400,
+			errors: []problemdetail.FieldError{
+				{Field: "id", Rule: "gte"},
+				{Field: "email", Rule: "email"},
+				{Field: "items[0].name", Rule: "required"},
+			},
+		},
+		{
+			name:        "wrong type",
+			path:        "/orders/1",
+			contentType: "application/json",
+			body:        `{"email":"a@b.io","items":[{"name":"pen","quantity":"two"}]}`,
+			status:      400,
+			errors:      []problemdetail.FieldError{{Field: "items[0].quantity", Rule: "type"}},
+		},
+		{name: "malformed json", path: "/orders/1", contentType: "application/json", body: `{"email":`, status: 400, errors: []problemdetail.FieldError{}},
+		{name: "unsupported content type", path: "/orders/1", contentType: "text/plain", body: "email", status: 415},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Fatalf("content type = %q", ct)
+			}
+
+			var problem problemdetail.ValidationProblemDetail
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Status != tt.status || problem.Instance != tt.path {
+				t.Fatalf("problem = %+v", problem)
+			}
+			if tt.errors == nil {
+				return
+			}
+			if len(problem.Errors) != len(tt.errors) {
+				t.Fatalf("errors = %+v, want %+v", problem.Errors, tt.errors)
+			}
+			for i, want := range tt.errors {
+				got := problem.Errors[i]
+				if got.Field != want.Field || got.Rule != want.Rule || got.Message == "" {
+					t.Fatalf("errors[%d] = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateWhileAddingLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	c.Request.Header.Set("Accept-Language", "fr")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := AddValidationLocale(fr.New(), frtranslations.RegisterDefaultTranslations); err != nil {
+			t.Error(err)
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if err := validate(c, &orderItem{}); err == nil {
+			t.Fatal("validate() = nil, want validation errors")
+		}
+	}
+	wg.Wait()
+}
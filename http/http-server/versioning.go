@@ -0,0 +1,319 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+)
+
+type VersionStrategy string
+
+const (
+	// HeaderVersioning version in a request header, e.g. version: v2
+	HeaderVersioning VersionStrategy = "header"
+	// QueryVersioning version in a query parameter, e.g. ?version=v2
+	QueryVersioning VersionStrategy = "query"
+	// MediaTypeVersioning version as a parameter of the Accept media type, e.g. application/json; version=v2
+	MediaTypeVersioning VersionStrategy = "mediaType"
+	// URLSegmentVersioning version as the first path segment after the base path, e.g. /api/v2/orders
+	URLSegmentVersioning VersionStrategy = "urlSegment"
+
+	defaultVersionName = "version"
+)
+
+var versionSegment = regexp.MustCompile(`^v\d+(\.\d+)?$`)
+
+// ApiVersion version of the api, Deprecated versions are still served with deprecation headers
+type ApiVersion struct {
+	Name       string
+	Deprecated bool
+	// DeprecatedAt date of the Deprecation header, the header is true when zero
+	DeprecatedAt time.Time
+	// Sunset date after which the version is removed, sent as Sunset header when set
+	Sunset time.Time
+	// Link documentation of the deprecation or migration, sent as Link header when set
+	Link string
+}
+
+type versionKey struct{}
+
+// VersionFromContext api version resolved for the request
+func VersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(versionKey{}).(string)
+	return version
+}
+
+// versionError unsupported or missing api version, written as problem details
+type versionError struct {
+	message string
+}
+
+func (e *versionError) Error() string {
+	return e.message
+}
+
+func (e *versionError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Versioning resolve the api version of requests under the base path before routing, and route them
+// to the group of that version, groups are registered under the base path as /<version>, requests
+// of routes registered outside the version groups, e.g. health endpoints on the engine root, are not versioned
+type Versioning struct {
+	strategy       VersionStrategy
+	name           string
+	defaultVersion string
+	basePath       string
+	engine         *gin.Engine
+	versions       map[string]*ApiVersion
+	groups         map[string]*gin.RouterGroup
+}
+
+// NewVersioning create versioning of versions from config.Versioning of server and resolve versions before routing,
+// the default version must be one of versions
+func NewVersioning(server *HttpServer, versions ...ApiVersion) (*Versioning, error) {
+	cfg := server.Config.Versioning
+	strategy := VersionStrategy(cfg.Strategy)
+	switch strategy {
+	case "":
+		strategy = HeaderVersioning
+	case HeaderVersioning, QueryVersioning, MediaTypeVersioning, URLSegmentVersioning:
+	default:
+		return nil, errors.Errorf("invalid versioning strategy %s", cfg.Strategy)
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = defaultVersionName
+	}
+
+	v := &Versioning{
+		strategy:       strategy,
+		name:           name,
+		defaultVersion: cfg.DefaultVersion,
+		basePath:       strings.TrimSuffix(server.Routes.BasePath(), "/"),
+		engine:         server.Engine,
+		versions:       map[string]*ApiVersion{},
+		groups:         map[string]*gin.RouterGroup{},
+	}
+	if len(versions) == 0 {
+		return nil, errors.New("versioning requires at least one version")
+	}
+	for i := range versions {
+		version := &versions[i]
+		if version.Name == "" || strings.Contains(version.Name, "/") {
+			return nil, errors.Errorf("invalid api version name %q", version.Name)
+		}
+		if _, ok := v.versions[version.Name]; ok {
+			return nil, errors.Errorf("api version %s is declared twice", version.Name)
+		}
+		v.versions[version.Name] = version
+
+		group := server.Routes.Group("/" + version.Name)
+		if version.Deprecated {
+			group.Use(deprecationHeaders(version))
+		}
+		v.groups[version.Name] = group
+	}
+	if v.defaultVersion != "" && !v.known(v.defaultVersion) {
+		return nil, errors.Errorf("default api version %s is not one of the versions %s", v.defaultVersion, v.supported())
+	}
+
+	server.WrapHandler(v.Handler)
+	return v, nil
+}
+
+// Group route group of version, responses of deprecated versions get the deprecation headers,
+// panics when version was not passed to NewVersioning
+func (v *Versioning) Group(version string) *gin.RouterGroup {
+	group, ok := v.groups[version]
+	if !ok {
+		panic(fmt.Sprintf("api version %s is not one of the versions %s", version, v.supported()))
+	}
+	return group
+}
+
+func deprecationHeaders(version *ApiVersion) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if version.DeprecatedAt.IsZero() {
+			c.Header("Deprecation", "true")
+		} else {
+			c.Header("Deprecation", fmt.Sprintf("@%d", version.DeprecatedAt.Unix()))
+		}
+		if !version.Sunset.IsZero() {
+			c.Header("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if version.Link != "" {
+			c.Header("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", version.Link))
+		}
+		c.Next()
+	}
+}
+
+// Handler resolve the version of requests under the base path, reject unknown versions and rewrite
+// the path to the group of the version, other requests are passed through unchanged, as are requests
+// of the routes registered outside the version groups when the handler is built
+func (v *Versioning) Handler(next http.Handler) http.Handler {
+	unversioned := v.unversionedRoutes()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := v.trimBasePath(r.URL.Path)
+		if !ok || matchesRoute(unversioned, rest) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		version, rest, err := v.resolve(r, rest)
+		if err != nil {
+			_, _ = problemdetail.ResolveProblemDetails(w, r, err)
+			return
+		}
+
+		switch v.strategy {
+		case HeaderVersioning:
+			w.Header().Add("Vary", v.name)
+		case MediaTypeVersioning:
+			w.Header().Add("Vary", "Accept")
+		}
+
+		// the url is shared with the request of the caller
+		u := *r.URL
+		u.Path = v.basePath + "/" + version + rest
+		u.RawPath = ""
+		r = r.WithContext(context.WithValue(r.Context(), versionKey{}, version))
+		r.URL = &u
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unversionedRoutes paths after the base path of the engine routes outside the version groups
+func (v *Versioning) unversionedRoutes() []string {
+	routes := []string{}
+	for _, route := range v.engine.Routes() {
+		rest, ok := v.trimBasePath(route.Path)
+		if !ok {
+			continue
+		}
+		segment, _, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+		if !v.known(segment) {
+			routes = append(routes, rest)
+		}
+	}
+	return routes
+}
+
+// matchesRoute true when path matches one of the gin route patterns
+func matchesRoute(routes []string, path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, route := range routes {
+		if matchRoute(strings.Split(strings.Trim(route, "/"), "/"), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRoute(route []string, path []string) bool {
+	for i, segment := range route {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(path) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if path[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != path[i] {
+			return false
+		}
+	}
+	return len(route) == len(path)
+}
+
+// trimBasePath path after the base path, false when path is not under it
+func (v *Versioning) trimBasePath(path string) (string, bool) {
+	if v.basePath == "" {
+		return path, true
+	}
+	rest, ok := strings.CutPrefix(path, v.basePath)
+	if !ok || rest != "" && !strings.HasPrefix(rest, "/") {
+		return "", false
+	}
+	return rest, true
+}
+
+// resolve version of the request, or the default version, and the path after the version segment,
+// a known version segment in the path is honoured with every strategy
+func (v *Versioning) resolve(r *http.Request, rest string) (string, string, error) {
+	var requested string
+	segment, after, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+	if v.known(segment) || v.strategy == URLSegmentVersioning && versionSegment.MatchString(segment) {
+		requested = segment
+		rest = ""
+		if after != "" {
+			rest = "/" + after
+		}
+	} else {
+		switch v.strategy {
+		case HeaderVersioning:
+			requested = r.Header.Get(v.name)
+		case QueryVersioning:
+			requested = r.URL.Query().Get(v.name)
+		case MediaTypeVersioning:
+			requested = mediaTypeVersion(r.Header.Values("Accept"), v.name)
+		}
+	}
+
+	if requested == "" {
+		if v.defaultVersion == "" {
+			return "", rest, &versionError{message: fmt.Sprintf("api version is required, supported versions: %s", v.supported())}
+		}
+		return v.defaultVersion, rest, nil
+	}
+	if v.known(requested) {
+		return requested, rest, nil
+	}
+	// accept 2 for v2
+	if v.known("v" + requested) {
+		return "v" + requested, rest, nil
+	}
+	return "", rest, &versionError{message: fmt.Sprintf("api version %s is not supported, supported versions: %s", requested, v.supported())}
+}
+
+func mediaTypeVersion(accept []string, name string) string {
+	for _, value := range accept {
+		for _, mediaRange := range strings.Split(value, ",") {
+			_, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err == nil && params[name] != "" {
+				return params[name]
+			}
+		}
+	}
+	return ""
+}
+
+func (v *Versioning) known(version string) bool {
+	_, ok := v.versions[version]
+	return ok
+}
+
+func (v *Versioning) supported() string {
+	names := make([]string, 0, len(v.versions))
+	for name := range v.versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
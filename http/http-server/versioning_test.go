@@ -0,0 +1,123 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-thread-7/commonlib/http/http-server/config"
+)
+
+// newVersionedServer server with v1 deprecated and v2 returning the resolved version, and a root health route
+func newVersionedServer(t *testing.T, basePath string, versioning config.VersioningConfig) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	server := NewServer(&config.HTTPConfig{BasePath: basePath, Versioning: versioning})
+	v, err := NewVersioning(server,
+		ApiVersion{Name: "v1", Deprecated: true, Sunset: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+		ApiVersion{Name: "v2"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []string{"v1", "v2"} {
+		v.Group(version).GET("/orders/:id", func(c *gin.Context) {
+			c.String(http.StatusOK, VersionFromContext(c.Request.Context())+" "+c.Param("id"))
+		})
+	}
+	server.Engine.GET("/health/live", func(c *gin.Context) { c.String(http.StatusOK, "live") })
+	return server.Handler()
+}
+
+func TestVersioningStrategies(t *testing.T) {
+	tests := []struct {
+		name       string
+		basePath   string
+		versioning config.VersioningConfig
+		path       string
+		header     http.Header
+		status     int
+		body       string
+	}{
+		{name: "header", basePath: "/api", path: "/api/orders/1", header: http.Header{"Version": {"v2"}}, status: 200, body: "v2 1"},
+		{name: "header without v", basePath: "/api", path: "/api/orders/1", header: http.Header{"Version": {"2"}}, status: 200, body: "v2 1"},
+		{name: "custom header", basePath: "/api", versioning: config.VersioningConfig{Name: "X-Api-Version"}, path: "/api/orders/1", header: http.Header{"X-Api-Version": {"v1"}}, status: 200, body: "v1 1"},
+		{name: "query", basePath: "/api", versioning: config.VersioningConfig{Strategy: "query"}, path: "/api/orders/1?version=v1", status: 200, body: "v1 1"},
+		{name: "media type", basePath: "/api", versioning: config.VersioningConfig{Strategy: "mediaType"}, path: "/api/orders/1", header: http.Header{"Accept": {"application/json; version=v2"}}, status: 200, body: "v2 1"},
+		{name: "url segment", basePath: "/api", versioning: config.VersioningConfig{Strategy: "urlSegment"}, path: "/api/v2/orders/1", status: 200, body: "v2 1"},
+		{name: "unknown url segment", basePath: "/api", versioning: config.VersioningConfig{Strategy: "urlSegment"}, path: "/api/v9/orders/1", status: 400},
+		{name: "default", basePath: "/api", versioning: config.VersioningConfig{DefaultVersion: "v2"}, path: "/api/orders/1", status: 200, body: "v2 1"},
+		{name: "missing", basePath: "/api", path: "/api/orders/1", status: 400},
+		{name: "unknown", basePath: "/api", path: "/api/orders/1", header: http.Header{"Version": {"v9"}}, status: 400},
+		{name: "outside base path", basePath: "/api", path: "/health/live", status: 200, body: "live"},
+		{name: "root base path", basePath: "/", path: "/orders/1", header: http.Header{"Version": {"v1"}}, status: 200, body: "v1 1"},
+		{name: "root route with root base path", basePath: "/", path: "/health/live", status: 200, body: "live"},
+		{name: "root route with version", basePath: "/", path: "/health/live", header: http.Header{"Version": {"v1"}}, status: 200, body: "live"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newVersionedServer(t, tt.basePath, tt.versioning)
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Fatalf("body = %q, want %q", w.Body, tt.body)
+			}
+		})
+	}
+}
+
+func TestDeprecationHeaders(t *testing.T) {
+	handler := newVersionedServer(t, "/api", config.VersioningConfig{})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/orders/1", nil)
+	r.Header.Set("version", "v1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if got := w.Header().Get("Deprecation"); got != "true" {
+		t.Fatalf("Deprecation = %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Tue, 01 Jan 2030 00:00:00 GMT" {
+		t.Fatalf("Sunset = %q", got)
+	}
+
+	r.Header.Set("version", "v2")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if got := w.Header().Get("Deprecation"); got != "" {
+		t.Fatalf("Deprecation of a current version = %q", got)
+	}
+}
+
+func TestNewVersioningValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		versioning config.VersioningConfig
+		versions   []ApiVersion
+	}{
+		{name: "unknown default version", versioning: config.VersioningConfig{DefaultVersion: "v3"}, versions: []ApiVersion{{Name: "v1"}}},
+		{name: "no version"},
+		{name: "duplicate version", versions: []ApiVersion{{Name: "v1"}, {Name: "v1"}}},
+		{name: "invalid strategy", versioning: config.VersioningConfig{Strategy: "cookie"}, versions: []ApiVersion{{Name: "v1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(&config.HTTPConfig{BasePath: "/api", Versioning: tt.versioning})
+			if _, err := NewVersioning(server, tt.versions...); err == nil {
+				t.Fatal("invalid versioning accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+type IdempotencyOptions struct {
+	HeaderName   string        `mapstructure:"headerName"`
+	LockTimeout  time.Duration `mapstructure:"lockTimeout"`
+	TTL          time.Duration `mapstructure:"ttl"`
+	WaitTimeout  time.Duration `mapstructure:"waitTimeout"`
+	PollInterval time.Duration `mapstructure:"pollInterval"`
+}
@@ -0,0 +1,139 @@
+package idempotency
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-thread-7/commonlib/idempotency/config"
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+)
+
+const (
+	replayedHeader        = "Idempotent-Replayed"
+	requestIDHeader       = "X-Request-Id"
+	rateLimitHeaderPrefix = "Ratelimit-"
+)
+
+// responseRecorder write the response through and keep a copy of the body
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// KeyFunc caller of a request, e.g. the authenticated user, idempotency keys are scoped to it
+// so that a caller cannot replay the responses of another caller
+type KeyFunc func(c *gin.Context) string
+
+// Middleware gin middleware replaying stored responses of requests with an idempotency key, responses with
+// a status code below 500 are stored, the key is released otherwise and when the handler adds errors with
+// c.Error, those are written by problemdetail.GinMiddleware after this middleware returns
+func Middleware(store Store, options *config.IdempotencyOptions, scope ...KeyFunc) gin.HandlerFunc {
+	o := withDefaults(options)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(o.HeaderName)
+		if key == "" {
+			c.Next()
+			return
+		}
+		for _, caller := range scope {
+			key = scopedKey(caller(c), key)
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				abortWithProblem(c, err)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		hash := requestHash([]byte(c.Request.Method), []byte(c.Request.URL.RequestURI()), body)
+
+		record, token, err := begin(c.Request.Context(), store, o, key, hash)
+		if err != nil {
+			abortWithProblem(c, err)
+			return
+		}
+		if record != nil {
+			replay(c, record.Response)
+			return
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				_ = store.Release(c.Request.Context(), key, token)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		if len(c.Errors) > 0 || recorder.Status() >= http.StatusInternalServerError {
+			if err := store.Release(c.Request.Context(), key, token); err != nil {
+				log.Printf("[idempotency_Middleware] release key %s error: %v\n", key, err)
+			}
+			return
+		}
+
+		response := &Response{
+			StatusCode: recorder.Status(),
+			Header:     storedHeader(recorder.Header()),
+			Body:       recorder.body.Bytes(),
+		}
+		if err := store.Complete(c.Request.Context(), key, token, response, o.TTL); err != nil {
+			log.Printf("[idempotency_Middleware] complete key %s error: %v\n", key, err)
+		}
+	}
+}
+
+// storedHeader response headers to replay, the request id and rate limit headers describe the request
+// being answered and are left to the middlewares of the duplicate request
+func storedHeader(header http.Header) http.Header {
+	stored := header.Clone()
+	for name := range stored {
+		if name == requestIDHeader || strings.HasPrefix(name, rateLimitHeaderPrefix) {
+			delete(stored, name)
+		}
+	}
+	return stored
+}
+
+// replay write a stored response, stored headers replace those already set by previous middlewares
+func replay(c *gin.Context, response *Response) {
+	header := c.Writer.Header()
+	for name, values := range response.Header {
+		header.Del(name)
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	c.Writer.Header().Set(replayedHeader, "true")
+	c.Writer.WriteHeader(response.StatusCode)
+	_, _ = c.Writer.Write(response.Body)
+	c.Abort()
+}
+
+func abortWithProblem(c *gin.Context, err error) {
+	_, _ = problemdetail.ResolveProblemDetails(c.Writer, c.Request, err)
+	c.Abort()
+}
@@ -0,0 +1,197 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	httpserver "github.com/go-thread-7/commonlib/http/http-server"
+	"github.com/go-thread-7/commonlib/http/http-server/config"
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+)
+
+// memoryStore store of the tests, expiry is not enforced
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: map[string]*Record{}}
+}
+
+func (s *memoryStore) Acquire(_ context.Context, key string, token string, requestHash string, _ time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok {
+		return record, false, nil
+	}
+	s.records[key] = &Record{Key: key, RequestHash: requestHash, Token: token}
+	return nil, true, nil
+}
+
+func (s *memoryStore) Complete(_ context.Context, key string, _ string, response *Response, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key].Response = response
+	return nil
+}
+
+func (s *memoryStore) Release(_ context.Context, key string, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		handler      gin.HandlerFunc
+		bodies       []string
+		wantStatus   []int
+		wantReplayed []bool
+		wantCalls    int
+	}{
+		{
+			name: "success is replayed",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusCreated, gin.H{"id": 1})
+			},
+			bodies:       []string{`{"a":1}`, `{"a":1}`},
+			wantStatus:   []int{http.StatusCreated, http.StatusCreated},
+			wantReplayed: []bool{false, true},
+			wantCalls:    1,
+		},
+		{
+			name: "client error added with c.Error is not stored",
+			handler: func(c *gin.Context) {
+				_ = c.Error(problemdetail.NewStatusError("duplicate order", http.StatusConflict))
+			},
+			bodies:       []string{`{"a":1}`, `{"a":1}`},
+			wantStatus:   []int{http.StatusConflict, http.StatusConflict},
+			wantReplayed: []bool{false, false},
+			wantCalls:    2,
+		},
+		{
+			name: "server error added with c.Error releases the key",
+			handler: func(c *gin.Context) {
+				_ = c.Error(problemdetail.NewStatusError("database unavailable", http.StatusServiceUnavailable))
+			},
+			bodies:       []string{`{"a":1}`, `{"a":1}`},
+			wantStatus:   []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			wantReplayed: []bool{false, false},
+			wantCalls:    2,
+		},
+		{
+			name: "key reused with another request",
+			handler: func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			},
+			bodies:       []string{`{"a":1}`, `{"a":2}`},
+			wantStatus:   []int{http.StatusNoContent, http.StatusUnprocessableEntity},
+			wantReplayed: []bool{false, false},
+			wantCalls:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httpserver.NewServer(&config.HTTPConfig{BasePath: "/api"}, Middleware(newMemoryStore(), nil))
+			server.Routes.POST("/orders", func(c *gin.Context) {
+				calls++
+				tt.handler(c)
+			})
+
+			for i, body := range tt.bodies {
+				req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Idempotency-Key", "k1")
+				w := httptest.NewRecorder()
+				server.Handler().ServeHTTP(w, req)
+
+				if w.Code != tt.wantStatus[i] {
+					t.Errorf("request %d status = %d, want %d", i, w.Code, tt.wantStatus[i])
+				}
+				if replayed := w.Header().Get(replayedHeader) == "true"; replayed != tt.wantReplayed[i] {
+					t.Errorf("request %d replayed = %t, want %t", i, replayed, tt.wantReplayed[i])
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMiddlewareScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	engine := gin.New()
+	engine.Use(Middleware(newMemoryStore(), nil, func(c *gin.Context) string {
+		return c.GetHeader("X-User")
+	}))
+	engine.POST("/orders", func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusCreated)
+	})
+
+	for _, user := range []string{"alice", "bob", "alice"} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set("Idempotency-Key", "k1")
+		req.Header.Set("X-User", user)
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 2 {
+		t.Errorf("handler calls = %d, want 2", calls)
+	}
+}
+
+func TestMiddlewareReplayHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	remaining := 10
+	limiter := func(c *gin.Context) {
+		remaining--
+		c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("Vary", "Accept")
+	}
+	server := httpserver.NewServer(&config.HTTPConfig{BasePath: "/api"}, limiter, Middleware(newMemoryStore(), nil))
+	server.Routes.POST("/orders", func(c *gin.Context) {
+		c.Header("Location", "/api/orders/1")
+		c.Status(http.StatusCreated)
+	})
+
+	var w *httptest.ResponseRecorder
+	for _, requestID := range []string{"r1", "r2"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/orders", nil)
+		req.Header.Set("Idempotency-Key", "k1")
+		req.Header.Set(httpserver.RequestIDHeader, requestID)
+		w = httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+	}
+
+	if w.Header().Get(replayedHeader) != "true" {
+		t.Fatal("second request was not replayed")
+	}
+	want := map[string]string{
+		httpserver.RequestIDHeader: "r2",
+		"RateLimit-Remaining":      "8",
+		"Vary":                     "Accept",
+		"Location":                 "/api/orders/1",
+	}
+	for name, value := range want {
+		if values := w.Header().Values(name); len(values) != 1 || values[0] != value {
+			t.Errorf("header %s = %v, want [%s]", name, values, value)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package idempotency
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/idempotency/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// GrpcKeyFunc caller of a call, e.g. the authenticated user, idempotency keys are scoped to it
+type GrpcKeyFunc func(ctx context.Context) string
+
+// UnaryServerInterceptor grpc interceptor replaying stored responses of calls with an idempotency key metadata,
+// successful responses are stored, the key is released when the handler fails
+func UnaryServerInterceptor(store Store, options *config.IdempotencyOptions, scope ...GrpcKeyFunc) grpc.UnaryServerInterceptor {
+	o := withDefaults(options)
+	metadataKey := strings.ToLower(o.HeaderName)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		keys := md.Get(metadataKey)
+		message, ok := req.(proto.Message)
+		if len(keys) == 0 || keys[0] == "" || !ok {
+			return handler(ctx, req)
+		}
+		key := keys[0]
+		for _, caller := range scope {
+			key = scopedKey(caller(ctx), key)
+		}
+
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		record, token, err := begin(ctx, store, o, key, requestHash([]byte(info.FullMethod), body))
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		if record != nil {
+			return replayMessage(record.Response)
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			if releaseErr := store.Release(ctx, key, token); releaseErr != nil {
+				log.Printf("[idempotency_UnaryServerInterceptor] release key %s error: %v\n", key, releaseErr)
+			}
+			return resp, err
+		}
+
+		if respMessage, ok := resp.(proto.Message); ok {
+			if err := complete(ctx, store, key, token, respMessage, o); err != nil {
+				log.Printf("[idempotency_UnaryServerInterceptor] complete key %s error: %v\n", key, err)
+			}
+		}
+		return resp, nil
+	}
+}
+
+func complete(ctx context.Context, store Store, key string, token string, message proto.Message, o config.IdempotencyOptions) error {
+	body, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return store.Complete(ctx, key, token, &Response{
+		Body:        body,
+		MessageType: string(message.ProtoReflect().Descriptor().FullName()),
+	}, o.TTL)
+}
+
+func replayMessage(response *Response) (interface{}, error) {
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(response.MessageType))
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.WrapIf(err, "failed to resolve stored response type").Error())
+	}
+	message := messageType.New().Interface()
+	if err := proto.Unmarshal(response.Body, message); err != nil {
+		return nil, status.Error(codes.Internal, errors.WrapIf(err, "failed to decode stored response").Error())
+	}
+	return message, nil
+}
+
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, ErrKeyReused):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrRequestInProgress):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
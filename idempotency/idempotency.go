@@ -0,0 +1,139 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/idempotency/config"
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+)
+
+const (
+	defaultHeaderName   = "Idempotency-Key"
+	defaultLockTimeout  = time.Minute
+	defaultTTL          = 24 * time.Hour
+	defaultPollInterval = 100 * time.Millisecond
+)
+
+// Response stored response replayed for duplicate requests
+type Response struct {
+	StatusCode int                 `json:"statusCode,omitempty"`
+	Header     map[string][]string `json:"header,omitempty"`
+	Body       []byte              `json:"body,omitempty"`
+	// MessageType proto message full name of grpc responses
+	MessageType string `json:"messageType,omitempty"`
+}
+
+// Record state of an idempotency key, Response is nil while the first request is processed
+type Record struct {
+	Key         string `json:"key"`
+	RequestHash string `json:"requestHash"`
+	// Token random token of the request holding the key
+	Token    string    `json:"token,omitempty"`
+	Response *Response `json:"response,omitempty"`
+}
+
+func (r *Record) Completed() bool {
+	return r.Response != nil
+}
+
+// Store persist idempotency records, keys are acquired with the random token of the request so that a request
+// outliving the lock timeout can neither complete nor release the key acquired by another request since
+type Store interface {
+	// Acquire reserve key for lockTimeout with token, the existing record is returned when the key is already known
+	Acquire(ctx context.Context, key string, token string, requestHash string, lockTimeout time.Duration) (record *Record, acquired bool, err error)
+	// Complete store the response of a key acquired with token for ttl, it fails when the key is no longer held
+	Complete(ctx context.Context, key string, token string, response *Response, ttl time.Duration) error
+	// Release remove a key acquired with token whose request failed so that it can be retried
+	Release(ctx context.Context, key string, token string) error
+}
+
+var (
+	ErrKeyReused         error = problemdetail.NewStatusError("idempotency key was already used with a different request", http.StatusUnprocessableEntity)
+	ErrRequestInProgress error = problemdetail.NewStatusError("a request with the same idempotency key is in progress", http.StatusConflict)
+)
+
+func withDefaults(options *config.IdempotencyOptions) config.IdempotencyOptions {
+	o := config.IdempotencyOptions{}
+	if options != nil {
+		o = *options
+	}
+	if o.HeaderName == "" {
+		o.HeaderName = defaultHeaderName
+	}
+	if o.LockTimeout <= 0 {
+		o.LockTimeout = defaultLockTimeout
+	}
+	if o.TTL <= 0 {
+		o.TTL = defaultTTL
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = defaultPollInterval
+	}
+	return o
+}
+
+// scopedKey key of caller, keys of requests without caller are not scoped
+func scopedKey(caller string, key string) string {
+	if caller == "" {
+		return key
+	}
+	return caller + ":" + key
+}
+
+func requestHash(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newToken random token identifying the request acquiring a key
+func newToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", errors.WrapIf(err, "failed to generate idempotency token")
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// begin acquire key for the request, a nil record means the caller owns the key with the returned token and must
+// process the request, duplicates of a request in progress are polled until it completes or options.WaitTimeout elapses
+func begin(ctx context.Context, store Store, options config.IdempotencyOptions, key string, hash string) (*Record, string, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+	deadline := time.Now().Add(options.WaitTimeout)
+	for {
+		record, acquired, err := store.Acquire(ctx, key, token, hash, options.LockTimeout)
+		if err != nil {
+			return nil, "", err
+		}
+		if acquired {
+			return nil, token, nil
+		}
+		if record.RequestHash != hash {
+			return nil, "", ErrKeyReused
+		}
+		if record.Completed() {
+			return record, "", nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, "", ErrRequestInProgress
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		case <-time.After(options.PollInterval):
+		}
+	}
+}
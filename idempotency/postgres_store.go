@@ -0,0 +1,107 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"emperror.dev/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+const postgresTableName = "idempotency_keys"
+
+type idempotencyKey struct {
+	Key         string    `gorm:"primaryKey;size:255"`
+	RequestHash string    `gorm:"size:64;not null"`
+	Token       string    `gorm:"size:32;not null;default:''"`
+	Response    []byte    `gorm:"type:jsonb"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time `gorm:"not null"`
+}
+
+func (idempotencyKey) TableName() string {
+	return postgresTableName
+}
+
+type postgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore create a store on the idempotency_keys table, e.g. with a connection from gormpg.New
+func NewPostgresStore(db *gorm.DB) Store {
+	return &postgresStore{db: db}
+}
+
+// MigratePostgresStore create or update the idempotency_keys table
+func MigratePostgresStore(db *gorm.DB) error {
+	return db.AutoMigrate(&idempotencyKey{})
+}
+
+func (s *postgresStore) Acquire(ctx context.Context, key string, token string, requestHash string, lockTimeout time.Duration) (*Record, bool, error) {
+	db := s.db.WithContext(ctx).Clauses(dbresolver.Write)
+	now := db.NowFunc()
+
+	if err := db.Where("key = ? AND expires_at < ?", key, now).Delete(&idempotencyKey{}).Error; err != nil {
+		return nil, false, errors.WrapIf(err, "failed to delete expired idempotency key")
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&idempotencyKey{
+		Key:         key,
+		RequestHash: requestHash,
+		Token:       token,
+		ExpiresAt:   now.Add(lockTimeout),
+	})
+	if result.Error != nil {
+		return nil, false, errors.WrapIf(result.Error, "failed to insert idempotency key")
+	}
+	if result.RowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	var existing idempotencyKey
+	err := db.Where("key = ?", key).Take(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// released or expired in the meantime, report it as in progress and let the caller retry
+		return &Record{Key: key, RequestHash: requestHash}, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.WrapIf(err, "failed to load idempotency key")
+	}
+
+	record := &Record{Key: existing.Key, RequestHash: existing.RequestHash, Token: existing.Token}
+	if existing.Response != nil {
+		if err := json.Unmarshal(existing.Response, &record.Response); err != nil {
+			return nil, false, errors.WrapIf(err, "failed to decode stored response")
+		}
+	}
+	return record, false, nil
+}
+
+// Complete store the response of the key while it is held by token, a key expired and acquired by
+// another request in the meantime holds another token and is left untouched
+func (s *postgresStore) Complete(ctx context.Context, key string, token string, response *Response, ttl time.Duration) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return errors.WrapIf(err, "failed to encode response")
+	}
+	db := s.db.WithContext(ctx)
+	result := db.Model(&idempotencyKey{}).Where("key = ? AND token = ? AND response IS NULL", key, token).Updates(map[string]any{
+		"response":   data,
+		"expires_at": db.NowFunc().Add(ttl),
+	})
+	if result.Error != nil {
+		return errors.WrapIf(result.Error, "failed to complete idempotency key")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("idempotency key is not held by the request")
+	}
+	return nil
+}
+
+func (s *postgresStore) Release(ctx context.Context, key string, token string) error {
+	err := s.db.WithContext(ctx).Where("key = ? AND token = ? AND response IS NULL", key, token).Delete(&idempotencyKey{}).Error
+	return errors.WrapIf(err, "failed to release idempotency key")
+}
@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newPostgresStore(t *testing.T) (Store, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPostgresStore(db), mock
+}
+
+func TestPostgresStoreAcquire(t *testing.T) {
+	store, mock := newPostgresStore(t)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "idempotency_keys" WHERE key = $1 AND expires_at < $2`)).
+		WithArgs("k1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "idempotency_keys" ("key","request_hash","token","response","expires_at","created_at") VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT DO NOTHING`)).
+		WithArgs("k1", "h1", "t1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, acquired, err := store.Acquire(context.Background(), "k1", "t1", "h1", time.Minute); err != nil || !acquired {
+		t.Fatalf("Acquire() = %t, %v, want acquired", acquired, err)
+	}
+}
+
+// a request outliving the lock timeout must neither complete nor release the key acquired by the next request,
+// the rows of the key held by the token of the next request are not matched
+func TestPostgresStoreExpiredLock(t *testing.T) {
+	ctx := context.Background()
+	store, mock := newPostgresStore(t)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "idempotency_keys" SET "expires_at"=$1,"response"=$2 WHERE key = $3 AND token = $4 AND response IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "k1", "slow").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "idempotency_keys" WHERE key = $1 AND token = $2 AND response IS NULL`)).
+		WithArgs("k1", "slow").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "idempotency_keys" SET "expires_at"=$1,"response"=$2 WHERE key = $3 AND token = $4 AND response IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "k1", "next").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := store.Complete(ctx, "k1", "slow", &Response{StatusCode: 200}, time.Hour); err == nil {
+		t.Error("Complete() with the token of the expired lock succeeded")
+	}
+	if err := store.Release(ctx, "k1", "slow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Complete(ctx, "k1", "next", &Response{StatusCode: 201}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,97 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "idempotency:"
+
+// completeScript store the response in the record of a key acquired with the token ARGV[3], a key
+// expired and acquired by another request in the meantime holds another token and is not overwritten
+var completeScript = redis.NewScript(`
+local stored = redis.call('GET', KEYS[1])
+if not stored then
+  return redis.error_reply('idempotency key is not acquired')
+end
+local record = cjson.decode(stored)
+if record.response then
+  return redis.error_reply('idempotency key is already completed')
+end
+if record.token ~= ARGV[3] then
+  return redis.error_reply('idempotency key is acquired by another request')
+end
+record.response = cjson.decode(ARGV[1])
+redis.call('SET', KEYS[1], cjson.encode(record), 'PX', ARGV[2])
+return 1
+`)
+
+// releaseScript remove the record of a key acquired with the token ARGV[1] unless its response is stored
+var releaseScript = redis.NewScript(`
+local stored = redis.call('GET', KEYS[1])
+if stored then
+  local record = cjson.decode(stored)
+  if not record.response and record.token == ARGV[1] then
+    redis.call('DEL', KEYS[1])
+  end
+end
+return 1
+`)
+
+type redisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore create a store on redis, e.g. with a client from redis.NewRedisClient
+func NewRedisStore(client *redis.Client) Store {
+	return &redisStore{client: client}
+}
+
+func (s *redisStore) Acquire(ctx context.Context, key string, token string, requestHash string, lockTimeout time.Duration) (*Record, bool, error) {
+	record := &Record{Key: key, RequestHash: requestHash, Token: token}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, errors.WrapIf(err, "failed to encode idempotency record")
+	}
+
+	acquired, err := s.client.SetNX(ctx, redisKeyPrefix+key, data, lockTimeout).Result()
+	if err != nil {
+		return nil, false, errors.WrapIf(err, "failed to set idempotency key")
+	}
+	if acquired {
+		return nil, true, nil
+	}
+
+	stored, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// released or expired in the meantime, report it as in progress and let the caller retry
+		return &Record{Key: key, RequestHash: requestHash}, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.WrapIf(err, "failed to get idempotency key")
+	}
+
+	existing := &Record{}
+	if err := json.Unmarshal(stored, existing); err != nil {
+		return nil, false, errors.WrapIf(err, "failed to decode idempotency record")
+	}
+	return existing, false, nil
+}
+
+func (s *redisStore) Complete(ctx context.Context, key string, token string, response *Response, ttl time.Duration) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return errors.WrapIf(err, "failed to encode idempotency response")
+	}
+	err = completeScript.Run(ctx, s.client, []string{redisKeyPrefix + key}, data, ttl.Milliseconds(), token).Err()
+	return errors.WrapIf(err, "failed to complete idempotency key")
+}
+
+func (s *redisStore) Release(ctx context.Context, key string, token string) error {
+	err := releaseScript.Run(ctx, s.client, []string{redisKeyPrefix + key}, token).Err()
+	return errors.WrapIf(err, "failed to release idempotency key")
+}
@@ -0,0 +1,117 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedisStore(t *testing.T) (Store, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return NewRedisStore(client), server
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	store, server := newRedisStore(t)
+
+	if _, acquired, err := store.Acquire(ctx, "k1", "t1", "h1", time.Minute); err != nil || !acquired {
+		t.Fatalf("Acquire() = %t, %v, want acquired", acquired, err)
+	}
+	record, acquired, err := store.Acquire(ctx, "k1", "t2", "h1", time.Minute)
+	if err != nil || acquired || record.Completed() {
+		t.Fatalf("Acquire() of a key in progress = %+v, %t, %v", record, acquired, err)
+	}
+
+	response := &Response{StatusCode: 201, Header: map[string][]string{"Content-Type": {"application/json"}}, Body: []byte(`{"id":1}`)}
+	if err := store.Complete(ctx, "k1", "t1", response, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL(redisKeyPrefix + "k1"); ttl != time.Hour {
+		t.Errorf("ttl = %s, want 1h", ttl)
+	}
+	if err := store.Complete(ctx, "k1", "t1", response, time.Hour); err == nil {
+		t.Error("Complete() of a completed key succeeded")
+	}
+
+	// a completed key is kept by Release
+	if err := store.Release(ctx, "k1", "t1"); err != nil {
+		t.Fatal(err)
+	}
+	record, _, err = store.Acquire(ctx, "k1", "t2", "h1", time.Minute)
+	if err != nil || !record.Completed() {
+		t.Fatalf("Acquire() of a completed key = %+v, %v", record, err)
+	}
+	if record.Response.StatusCode != 201 || string(record.Response.Body) != `{"id":1}` ||
+		record.Response.Header["Content-Type"][0] != "application/json" {
+		t.Errorf("stored response = %+v", record.Response)
+	}
+}
+
+func TestRedisStoreRelease(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newRedisStore(t)
+
+	if _, _, err := store.Acquire(ctx, "k1", "t1", "h1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Release(ctx, "k1", "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, acquired, err := store.Acquire(ctx, "k1", "t2", "h2", time.Minute); err != nil || !acquired {
+		t.Fatalf("Acquire() of a released key = %t, %v, want acquired", acquired, err)
+	}
+}
+
+func TestRedisStoreCompleteExpired(t *testing.T) {
+	ctx := context.Background()
+	store, server := newRedisStore(t)
+
+	if _, _, err := store.Acquire(ctx, "k1", "t1", "h1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(2 * time.Minute)
+	if err := store.Complete(ctx, "k1", "t1", &Response{StatusCode: 200}, time.Hour); err == nil {
+		t.Error("Complete() of an expired key succeeded")
+	}
+}
+
+// a request outliving the lock timeout must neither complete nor release the key acquired by the next request
+func TestRedisStoreExpiredLock(t *testing.T) {
+	ctx := context.Background()
+	store, server := newRedisStore(t)
+
+	if _, _, err := store.Acquire(ctx, "k1", "slow", "h1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(2 * time.Minute)
+	if _, acquired, err := store.Acquire(ctx, "k1", "next", "h1", time.Minute); err != nil || !acquired {
+		t.Fatalf("Acquire() of an expired key = %t, %v, want acquired", acquired, err)
+	}
+
+	if err := store.Complete(ctx, "k1", "slow", &Response{StatusCode: 200}, time.Hour); err == nil {
+		t.Error("Complete() with the token of the expired lock succeeded")
+	}
+	if err := store.Release(ctx, "k1", "slow"); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists(redisKeyPrefix + "k1") {
+		t.Fatal("Release() with the token of the expired lock removed the key of the next request")
+	}
+
+	if err := store.Complete(ctx, "k1", "next", &Response{StatusCode: 201}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	record, _, err := store.Acquire(ctx, "k1", "other", "h1", time.Minute)
+	if err != nil || !record.Completed() || record.Response.StatusCode != 201 {
+		t.Fatalf("Acquire() of the completed key = %+v, %v", record, err)
+	}
+}
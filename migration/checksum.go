@@ -0,0 +1,193 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/migration/contracts"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/lib/pq"
+)
+
+const checksumTableSuffix = "_checksums"
+
+// guarded run fn under the migration lock after checking the applied migrations for drift,
+// then record the checksums of the migrations applied by fn
+func (m *migrator) guarded(ctx context.Context, operation string, fn func() error) error {
+	return m.withLock(ctx, operation, func() error {
+		if err := m.checkDrift(ctx); err != nil {
+			return err
+		}
+
+		err := fn()
+		if recordErr := m.recordChecksums(ctx); recordErr != nil {
+			if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+				log.Printf("failed to record migration checksums: %v\n", recordErr)
+				return err
+			}
+			return recordErr
+		}
+		return err
+	})
+}
+
+// Verify compare the recorded checksums of the applied migrations with the migration files,
+// migrations applied before checksums were recorded are trusted and recorded on the next migration
+func (m *migrator) Verify(ctx context.Context) ([]contracts.ChecksumDrift, error) {
+	drifts := []contracts.ChecksumDrift{}
+
+	var exists bool
+	if err := m.conn.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", m.checksumTable()).Scan(&exists); err != nil {
+		return nil, errors.WrapIf(err, "failed to read migration checksums")
+	}
+	if !exists {
+		return drifts, nil
+	}
+
+	recorded, err := m.recordedChecksums(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, err := m.fileChecksums()
+	if err != nil {
+		return nil, err
+	}
+
+	for version, checksum := range recorded {
+		if current[version] != checksum {
+			drifts = append(drifts, contracts.ChecksumDrift{Version: version, Recorded: checksum, Current: current[version]})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Version < drifts[j].Version })
+	return drifts, nil
+}
+
+// checkDrift log drifted migrations, and refuse to migrate when config.StrictChecksums is set
+func (m *migrator) checkDrift(ctx context.Context) error {
+	drifts, err := m.Verify(ctx)
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	for _, drift := range drifts {
+		if drift.Current == "" {
+			log.Printf("migration drift: applied migration %d is missing\n", drift.Version)
+		} else {
+			log.Printf("migration drift: applied migration %d changed, recorded checksum %s, file checksum %s\n",
+				drift.Version, drift.Recorded, drift.Current)
+		}
+	}
+	if m.config.StrictChecksums {
+		return &DriftError{Drifts: drifts, ChecksumTable: m.checksumTable()}
+	}
+	return nil
+}
+
+// recordChecksums record the checksums of applied migrations not recorded yet
+// and remove the checksums of reverted migrations
+func (m *migrator) recordChecksums(ctx context.Context) error {
+	table := m.checksumTable()
+	_, err := m.conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version bigint PRIMARY KEY,
+		checksum text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now())`, table))
+	if err != nil {
+		return errors.WrapIf(err, "failed to create migration checksums table")
+	}
+
+	version, dirty, err := m.migration.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		_, err = m.conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", table))
+		return errors.WrapIf(err, "failed to remove migration checksums")
+	}
+	if err != nil {
+		return errors.WrapIf(err, "failed to read migration version")
+	}
+
+	// a dirty version is not fully applied
+	applied := func(v uint) bool { return v < version || v == version && !dirty }
+	if dirty {
+		_, err = m.conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version >= $1", table), int64(version))
+	} else {
+		_, err = m.conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version > $1", table), int64(version))
+	}
+	if err != nil {
+		return errors.WrapIf(err, "failed to remove migration checksums")
+	}
+
+	checksums, err := m.fileChecksums()
+	if err != nil {
+		return err
+	}
+	for v, checksum := range checksums {
+		if !applied(v) {
+			continue
+		}
+		_, err := m.conn.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (version, checksum) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING", table), int64(v), checksum)
+		if err != nil {
+			return errors.WrapIf(err, fmt.Sprintf("failed to record checksum of migration %d", v))
+		}
+	}
+	return nil
+}
+
+func (m *migrator) recordedChecksums(ctx context.Context) (map[uint]string, error) {
+	rows, err := m.conn.QueryContext(ctx, fmt.Sprintf("SELECT version, checksum FROM %s", m.checksumTable()))
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to read migration checksums")
+	}
+	defer rows.Close()
+
+	checksums := map[uint]string{}
+	for rows.Next() {
+		var version int64
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, errors.WrapIf(err, "failed to read migration checksums")
+		}
+		checksums[uint(version)] = checksum
+	}
+	return checksums, errors.WrapIf(rows.Err(), "failed to read migration checksums")
+}
+
+// fileChecksums sha256 of the up migration of every source version
+func (m *migrator) fileChecksums() (map[uint]string, error) {
+	versions, err := m.sourceVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[uint]string, len(versions))
+	for _, version := range versions {
+		migration, err := readMigration(m.source.ReadUp, version, "up")
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256([]byte(migration.Body))
+		checksums[version] = hex.EncodeToString(sum[:])
+	}
+	return checksums, nil
+}
+
+// checksumTable quoted name of the checksums table, stored next to the version table
+func (m *migrator) checksumTable() string {
+	table := m.config.VersionTable
+	if table == "" {
+		table = postgres.DefaultMigrationsTable
+	}
+	name := pq.QuoteIdentifier(table + checksumTableSuffix)
+	if m.config.SchemaName != "" {
+		name = pq.QuoteIdentifier(m.config.SchemaName) + "." + name
+	}
+	return name
+}
@@ -0,0 +1,113 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"emperror.dev/errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+func checksum(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerify(t *testing.T) {
+	files := fstest.MapFS{
+		"1_init.up.sql":  {Data: []byte("CREATE TABLE t (id int);")},
+		"2_index.up.sql": {Data: []byte("CREATE INDEX t_id ON t (id);")},
+	}
+
+	tests := []struct {
+		name     string
+		exists   bool
+		recorded map[uint]string
+		want     map[uint]string
+		strict   bool
+	}{
+		{name: "no checksums table"},
+		{
+			name:     "unchanged",
+			exists:   true,
+			recorded: map[uint]string{1: checksum("CREATE TABLE t (id int);")},
+			want:     map[uint]string{},
+		},
+		{
+			name:     "changed",
+			exists:   true,
+			recorded: map[uint]string{1: checksum("CREATE TABLE t (id bigint);")},
+			want:     map[uint]string{1: checksum("CREATE TABLE t (id int);")},
+		},
+		{
+			name:     "missing",
+			exists:   true,
+			recorded: map[uint]string{3: checksum("DROP TABLE t;")},
+			want:     map[uint]string{3: ""},
+		},
+		{
+			name:     "strict",
+			exists:   true,
+			recorded: map[uint]string{3: checksum("DROP TABLE t;")},
+			want:     map[uint]string{3: ""},
+			strict:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			sqlDB, _ := db.DB()
+			sourceDriver, err := iofs.New(files, ".")
+			if err != nil {
+				t.Fatal(err)
+			}
+			options := testOptions(files)
+			options.StrictChecksums = tt.strict
+			conn, err := sqlDB.Conn(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := &migrator{config: options, sqlDB: sqlDB, conn: conn, source: sourceDriver}
+
+			// Verify is run twice, by itself and by checkDrift
+			for i := 0; i < 2; i++ {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+					WithArgs(`"public"."schema_migrations_checksums"`).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.exists))
+				if tt.exists {
+					rows := sqlmock.NewRows([]string{"version", "checksum"})
+					for version, sum := range tt.recorded {
+						rows.AddRow(int64(version), sum)
+					}
+					mock.ExpectQuery(regexp.QuoteMeta(`SELECT version, checksum FROM "public"."schema_migrations_checksums"`)).
+						WillReturnRows(rows)
+				}
+			}
+
+			drifts, err := m.Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(drifts) != len(tt.want) {
+				t.Fatalf("drifts = %+v, want %v", drifts, tt.want)
+			}
+			for _, drift := range drifts {
+				current, ok := tt.want[drift.Version]
+				if !ok || drift.Current != current || drift.Recorded != tt.recorded[drift.Version] {
+					t.Fatalf("drift = %+v, want current %q", drift, current)
+				}
+			}
+
+			err = m.checkDrift(context.Background())
+			var driftErr *DriftError
+			if tt.strict != errors.As(err, &driftErr) || !tt.strict && err != nil {
+				t.Fatalf("checkDrift() error = %v, strict %t", err, tt.strict)
+			}
+		})
+	}
+}
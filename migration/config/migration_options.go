@@ -1,5 +1,10 @@
 package config
 
+import (
+	"io/fs"
+	"time"
+)
+
 type CommandType string
 
 const (
@@ -8,13 +13,20 @@ const (
 )
 
 type MigrationOptions struct {
-	Host          string `mapstructure:"host"`
-	Port          int    `mapstructure:"port"`
-	User          string `mapstructure:"user"`
-	DBName        string `mapstructure:"dbName"`
-	SSLMode       bool   `mapstructure:"sslMode"`
-	Password      string `mapstructure:"password"`
-	VersionTable  string `mapstructure:"versionTable"`
-	MigrationsDir string `mapstructure:"migrationsDir"`
-	SkipMigration bool   `mapstructure:"skipMigration"`
+	Host          string        `mapstructure:"host"`
+	Port          int           `mapstructure:"port"`
+	User          string        `mapstructure:"user"`
+	DBName        string        `mapstructure:"dbName"`
+	SSLMode       bool          `mapstructure:"sslMode"`
+	Password      string        `mapstructure:"password"`
+	VersionTable  string        `mapstructure:"versionTable"`
+	SchemaName    string        `mapstructure:"schemaName"`
+	MigrationsDir string        `mapstructure:"migrationsDir"`
+	SkipMigration bool          `mapstructure:"skipMigration"`
+	LockKey       int64         `mapstructure:"lockKey"`
+	LockTimeout   time.Duration `mapstructure:"lockTimeout"`
+	// StrictChecksums refuse to migrate when an applied migration file changed or disappeared
+	StrictChecksums bool `mapstructure:"strictChecksums"`
+	// MigrationsFS embedded migrations source, e.g. from //go:embed, MigrationsDir is then the directory inside it
+	MigrationsFS fs.FS `mapstructure:"-"`
 }
@@ -2,7 +2,38 @@ package contracts
 
 import "context"
 
+// MigrationStatus state of the database schema, Version is 0 when no migration is applied
+type MigrationStatus struct {
+	Version uint
+	Dirty   bool
+	Pending []uint
+	Drift   []ChecksumDrift
+}
+
+// ChecksumDrift applied migration whose file changed since it was applied, Current is empty when the file is missing
+type ChecksumDrift struct {
+	Version  uint
+	Recorded string
+	Current  string
+}
+
+// PlannedMigration migration that would be run, Body is the SQL, empty when the file is missing
+type PlannedMigration struct {
+	Version    uint
+	Identifier string
+	Direction  string
+	Body       string
+}
+
 type PostgresMigrationRunner interface {
 	Up(ctx context.Context, version uint) error
 	Down(ctx context.Context, version uint) error
+	Status(ctx context.Context) (*MigrationStatus, error)
+	Steps(ctx context.Context, n int) error
+	Force(ctx context.Context, version int) error
+	Drop(ctx context.Context) error
+	Plan(ctx context.Context, n int) ([]PlannedMigration, error)
+	Verify(ctx context.Context) ([]ChecksumDrift, error)
+	// Close release the connection held by the runner
+	Close() error
 }
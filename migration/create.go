@@ -0,0 +1,43 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+const versionTimeFormat = "20060102150405"
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create write empty up and down migration files in dir, versioned with the current UTC time
+// so that migrations created on different branches do not collide, returns the file paths
+func Create(dir string, name string) ([]string, error) {
+	name = strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, errors.New("migration name is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.WrapIf(err, "failed to create migrations directory")
+	}
+
+	version := time.Now().UTC().Format(versionTimeFormat)
+	files := []string{}
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return files, errors.WrapIf(err, "failed to create migration file")
+		}
+		if err := file.Close(); err != nil {
+			return files, errors.WrapIf(err, "failed to create migration file")
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
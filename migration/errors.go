@@ -0,0 +1,38 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-thread-7/commonlib/migration/contracts"
+)
+
+// DirtyError returned when a previous migration failed halfway and left the database dirty
+type DirtyError struct {
+	Version         uint
+	PreviousVersion int
+}
+
+func (e *DirtyError) Error() string {
+	return fmt.Sprintf("database is dirty at version %d because a migration failed halfway: "+
+		"repair the partially applied changes manually, then run Force(%d) if migration %d is now fully applied "+
+		"or Force(%d) to mark it as not applied and run it again",
+		e.Version, e.Version, e.Version, e.PreviousVersion)
+}
+
+// DriftError returned in strict mode when applied migrations changed or disappeared since they were applied
+type DriftError struct {
+	Drifts        []contracts.ChecksumDrift
+	ChecksumTable string
+}
+
+func (e *DriftError) Error() string {
+	versions := make([]string, 0, len(e.Drifts))
+	for _, drift := range e.Drifts {
+		versions = append(versions, fmt.Sprint(drift.Version))
+	}
+	return fmt.Sprintf("applied migrations %s changed since they were applied: "+
+		"restore the original files and add a new migration for the change, "+
+		"or if the database already matches the changed files delete their rows from %s to record them again",
+		strings.Join(versions, ", "), e.ChecksumTable)
+}
@@ -0,0 +1,173 @@
+package migration
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/source"
+	"gorm.io/gorm"
+)
+
+const goMigrationMarker = "-- go migration"
+
+// GoMigrationFunc migration step written in Go, e.g. a data backfill, run inside a transaction
+type GoMigrationFunc func(tx *gorm.DB) error
+
+type goMigration struct {
+	version uint
+	name    string
+	up      GoMigrationFunc
+	down    GoMigrationFunc
+}
+
+var goMigrations = map[uint]*goMigration{}
+
+// Register register a Go migration run in version order with the SQL migrations and recorded in
+// the same version table, down may be nil, versions must not collide with SQL migration versions,
+// a migrate command runs them only when it imports the package registering them, see migratecmd
+func Register(version uint, name string, up GoMigrationFunc, down GoMigrationFunc) {
+	if up == nil {
+		panic(fmt.Sprintf("go migration %d has no up func", version))
+	}
+	if _, ok := goMigrations[version]; ok {
+		panic(fmt.Sprintf("go migration %d already registered", version))
+	}
+	goMigrations[version] = &goMigration{version: version, name: name, up: up, down: down}
+}
+
+// goSource source driver merging the SQL migrations with the registered Go migrations,
+// Go migrations are read as a marker executed by goDatabase
+type goSource struct {
+	sql        source.Driver
+	migrations map[uint]*goMigration
+	versions   []uint
+}
+
+func newGoSource(sqlSource source.Driver, migrations map[uint]*goMigration) (*goSource, error) {
+	s := &goSource{sql: sqlSource, migrations: migrations}
+
+	version, err := sqlSource.First()
+	for err == nil {
+		if _, ok := migrations[version]; ok {
+			return nil, errors.Errorf("go migration %d collides with a sql migration", version)
+		}
+		s.versions = append(s.versions, version)
+		version, err = sqlSource.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.WrapIf(err, "failed to read sql migrations")
+	}
+
+	for version := range migrations {
+		s.versions = append(s.versions, version)
+	}
+	sort.Slice(s.versions, func(i, j int) bool { return s.versions[i] < s.versions[j] })
+	return s, nil
+}
+
+func (s *goSource) Open(_ string) (source.Driver, error) {
+	return nil, errors.New("go migration source cannot be opened from url")
+}
+
+func (s *goSource) Close() error {
+	return s.sql.Close()
+}
+
+func (s *goSource) First() (uint, error) {
+	if len(s.versions) == 0 {
+		return 0, &os.PathError{Op: "first", Path: "migrations", Err: os.ErrNotExist}
+	}
+	return s.versions[0], nil
+}
+
+func (s *goSource) Prev(version uint) (uint, error) {
+	i := s.index(version)
+	if i <= 0 {
+		return 0, &os.PathError{Op: "prev", Path: "migrations", Err: os.ErrNotExist}
+	}
+	return s.versions[i-1], nil
+}
+
+func (s *goSource) Next(version uint) (uint, error) {
+	i := s.index(version)
+	if i < 0 || i+1 >= len(s.versions) {
+		return 0, &os.PathError{Op: "next", Path: "migrations", Err: os.ErrNotExist}
+	}
+	return s.versions[i+1], nil
+}
+
+func (s *goSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	if m, ok := s.migrations[version]; ok {
+		return io.NopCloser(strings.NewReader(fmt.Sprintf("%s %d up", goMigrationMarker, version))), m.name, nil
+	}
+	return s.sql.ReadUp(version)
+}
+
+func (s *goSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	if m, ok := s.migrations[version]; ok {
+		if m.down == nil {
+			return nil, "", &os.PathError{Op: "read down", Path: m.name, Err: os.ErrNotExist}
+		}
+		return io.NopCloser(strings.NewReader(fmt.Sprintf("%s %d down", goMigrationMarker, version))), m.name, nil
+	}
+	return s.sql.ReadDown(version)
+}
+
+func (s *goSource) index(version uint) int {
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] >= version })
+	if i < len(s.versions) && s.versions[i] == version {
+		return i
+	}
+	return -1
+}
+
+// goDatabase database driver running Go migration markers in a gorm transaction and SQL migrations as usual
+type goDatabase struct {
+	database.Driver
+	db         *gorm.DB
+	migrations map[uint]*goMigration
+}
+
+func (d *goDatabase) Run(migration io.Reader) error {
+	body, err := io.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(body, []byte(goMigrationMarker)) {
+		return d.Driver.Run(bytes.NewReader(body))
+	}
+
+	var version uint
+	var direction string
+	if _, err := fmt.Sscanf(string(body[len(goMigrationMarker):]), "%d %s", &version, &direction); err != nil {
+		return errors.WrapIf(err, "invalid go migration marker")
+	}
+	m, ok := d.migrations[version]
+	if !ok {
+		return errors.Errorf("go migration %d is not registered", version)
+	}
+
+	fn := m.up
+	if direction == "down" {
+		fn = m.down
+	}
+	if err := d.db.Transaction(fn); err != nil {
+		return errors.WrapIf(err, fmt.Sprintf("go migration %d %s failed", version, m.name))
+	}
+	return nil
+}
+
+// registeredGoMigrations snapshot of the registered Go migrations
+func registeredGoMigrations() map[uint]*goMigration {
+	migrations := make(map[uint]*goMigration, len(goMigrations))
+	for version, m := range goMigrations {
+		migrations[version] = m
+	}
+	return migrations
+}
@@ -0,0 +1,164 @@
+package migration
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"emperror.dev/errors"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"gorm.io/gorm"
+)
+
+func newSQLSource(t *testing.T) *goSource {
+	t.Helper()
+	sqlSource, err := iofs.New(fstest.MapFS{
+		"1_init.up.sql":   {Data: []byte("CREATE TABLE t (id int);")},
+		"1_init.down.sql": {Data: []byte("DROP TABLE t;")},
+		"3_index.up.sql":  {Data: []byte("CREATE INDEX t_id ON t (id);")},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noop := func(*gorm.DB) error { return nil }
+	s, err := newGoSource(sqlSource, map[uint]*goMigration{
+		2: {version: 2, name: "backfill", up: noop},
+		4: {version: 4, name: "cleanup", up: noop, down: noop},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestGoSourceOrder(t *testing.T) {
+	s := newSQLSource(t)
+
+	versions := []uint{}
+	version, err := s.First()
+	for err == nil {
+		versions = append(versions, version)
+		version, err = s.Next(version)
+	}
+	if want := []uint{1, 2, 3, 4}; !equalVersions(versions, want) {
+		t.Fatalf("versions = %v, want %v", versions, want)
+	}
+	if prev, err := s.Prev(3); err != nil || prev != 2 {
+		t.Fatalf("Prev(3) = %d, %v, want 2", prev, err)
+	}
+	if _, err := s.Prev(1); err == nil {
+		t.Fatal("Prev(1) found a version before the first")
+	}
+}
+
+func TestGoSourceRead(t *testing.T) {
+	s := newSQLSource(t)
+
+	tests := []struct {
+		name    string
+		read    func() (io.ReadCloser, string, error)
+		body    string
+		missing bool
+	}{
+		{name: "sql up", read: func() (io.ReadCloser, string, error) { return s.ReadUp(1) }, body: "CREATE TABLE t (id int);"},
+		{name: "go up", read: func() (io.ReadCloser, string, error) { return s.ReadUp(2) }, body: goMigrationMarker + " 2 up"},
+		{name: "go down", read: func() (io.ReadCloser, string, error) { return s.ReadDown(4) }, body: goMigrationMarker + " 4 down"},
+		{name: "go without down", read: func() (io.ReadCloser, string, error) { return s.ReadDown(2) }, missing: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _, err := tt.read()
+			if tt.missing {
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Fatalf("error = %v, want not exist", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			body, _ := io.ReadAll(r)
+			if string(body) != tt.body {
+				t.Fatalf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestGoSourceCollision(t *testing.T) {
+	sqlSource, err := iofs.New(fstest.MapFS{"1_init.up.sql": {Data: []byte("SELECT 1;")}}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = newGoSource(sqlSource, map[uint]*goMigration{1: {version: 1, name: "backfill"}})
+	if err == nil || !strings.Contains(err.Error(), "collides") {
+		t.Fatalf("error = %v, want collision", err)
+	}
+}
+
+// recordingDriver database driver recording the SQL migrations it runs
+type recordingDriver struct {
+	database.Driver
+	ran []string
+}
+
+func (d *recordingDriver) Run(migration io.Reader) error {
+	body, err := io.ReadAll(migration)
+	d.ran = append(d.ran, string(body))
+	return err
+}
+
+func TestGoDatabaseRun(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	failure := errors.New("backfill failed")
+	calls := 0
+	driver := &recordingDriver{}
+	d := &goDatabase{Driver: driver, db: db, migrations: map[uint]*goMigration{
+		2: {version: 2, name: "backfill", up: func(*gorm.DB) error { calls++; return nil }},
+		3: {version: 3, name: "broken", up: func(*gorm.DB) error { return failure }},
+	}}
+
+	if err := d.Run(strings.NewReader("CREATE TABLE t (id int);")); err != nil {
+		t.Fatal(err)
+	}
+	if len(driver.ran) != 1 {
+		t.Fatalf("sql migrations run = %v, want 1", driver.ran)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	if err := d.Run(strings.NewReader(goMigrationMarker + " 2 up")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("go migration calls = %d, want 1", calls)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	if err := d.Run(strings.NewReader(goMigrationMarker + " 3 up")); !errors.Is(err, failure) {
+		t.Fatalf("error = %v, want %v", err, failure)
+	}
+
+	if err := d.Run(strings.NewReader(goMigrationMarker + " 9 up")); err == nil {
+		t.Fatal("unregistered go migration ran")
+	}
+}
+
+func equalVersions(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+const (
+	defaultLockTimeout = 5 * time.Minute
+	lockPollInterval   = time.Second
+)
+
+// withLock run fn while holding a session level postgres advisory lock, so that only one replica
+// migrates at a time, other replicas wait up to config.LockTimeout. The lock is taken on the connection
+// of the runner, the session running the migrations, so that no second connection of the pool is needed
+func (m *migrator) withLock(ctx context.Context, operation string, fn func() error) error {
+	timeout := m.config.LockTimeout
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+	key := m.lockKey()
+	conn := m.conn
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	holderLogged := false
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+			return errors.WrapIf(err, "failed to acquire migration lock")
+		}
+		if acquired {
+			break
+		}
+		if !holderLogged {
+			logLockHolder(ctx, conn, key)
+			holderLogged = true
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return errors.Errorf("timed out after %s waiting for migration lock %d", time.Since(start).Round(time.Millisecond), key)
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	log.Printf("migration lock %d acquired for %s after waiting %s\n", key, operation, time.Since(start).Round(time.Millisecond))
+
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			log.Printf("failed to release migration lock %d: %v\n", key, err)
+		}
+	}()
+
+	return fn()
+}
+
+// lockKey config.LockKey or a key derived from the database, schema and version table
+func (m *migrator) lockKey() int64 {
+	if m.config.LockKey != 0 {
+		return m.config.LockKey
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("migration:" + m.config.DBName + ":" + m.config.SchemaName + ":" + m.config.VersionTable))
+	return int64(h.Sum64())
+}
+
+func logLockHolder(ctx context.Context, conn *sql.Conn, key int64) {
+	var pid int
+	var applicationName, clientAddr string
+	var backendStart time.Time
+	err := conn.QueryRowContext(ctx, `SELECT a.pid, a.application_name, COALESCE(host(a.client_addr), 'local'), a.backend_start
+		FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1
+		AND l.classid = (($1::bigint >> 32) & 4294967295)::bigint::oid AND l.objid = ($1::bigint & 4294967295)::bigint::oid
+		LIMIT 1`, key).Scan(&pid, &applicationName, &clientAddr, &backendStart)
+	if err != nil {
+		log.Printf("waiting for migration lock %d held by another session\n", key)
+		return
+	}
+	log.Printf("waiting for migration lock %d held by pid %d (application %q, client %s, connected since %s)\n",
+		key, pid, applicationName, clientAddr, backendStart.Format(time.RFC3339))
+}
@@ -0,0 +1,119 @@
+package migration
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// the runner holds one connection, the lock and the checksums must not wait for a second one
+func TestForceOnSingleConnectionPool(t *testing.T) {
+	db, mock := newMockDB(t)
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	files := fstest.MapFS{"1_init.up.sql": {Data: []byte("CREATE TABLE t (id int);")}}
+
+	expectVersionTable(mock)
+	runner, err := New(testOptions(files), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runner.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_try_advisory_lock($1)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`TRUNCATE "public"."schema_migrations"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "public"."schema_migrations" (version, dirty) VALUES ($1, $2)`)).
+		WithArgs(1, false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	table := `"public"."schema_migrations_checksums"`
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS ` + table)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version, dirty FROM "public"."schema_migrations" LIMIT 1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM ` + table + ` WHERE version > $1`)).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO `+table+` (version, checksum) VALUES ($1, $2)`)).
+		WithArgs(int64(1), checksum("CREATE TABLE t (id int);")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runner.Force(context.Background(), 1)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Force() waits for a second connection of the pool")
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	t.Run("held by another session", func(t *testing.T) {
+		m, _, mock := newStubRunner(t)
+		m.config.LockTimeout = 10 * time.Millisecond
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_try_advisory_lock($1)`)).
+			WithArgs(m.lockKey()).
+			WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(false))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT a.pid, a.application_name`)).
+			WithArgs(m.lockKey()).
+			WillReturnRows(sqlmock.NewRows([]string{"pid", "application_name", "client_addr", "backend_start"}).
+				AddRow(42, "migrate", "10.0.0.2", time.Now()))
+
+		called := false
+		err := m.withLock(context.Background(), "up", func() error {
+			called = true
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Fatalf("withLock() error = %v, want a timeout", err)
+		}
+		if called {
+			t.Error("fn ran without the lock")
+		}
+	})
+
+	t.Run("released when fn fails", func(t *testing.T) {
+		m, _, mock := newStubRunner(t)
+		mock.MatchExpectationsInOrder(true)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_try_advisory_lock($1)`)).
+			WithArgs(m.lockKey()).
+			WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+		mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).
+			WithArgs(m.lockKey()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		failed := errors.New("migration failed")
+		if err := m.withLock(context.Background(), "up", func() error { return failed }); !errors.Is(err, failed) {
+			t.Fatalf("withLock() error = %v, want %v", err, failed)
+		}
+	})
+}
+
+func TestLockKey(t *testing.T) {
+	m := &migrator{config: testOptions(nil)}
+	other := &migrator{config: testOptions(nil)}
+	other.config.SchemaName = "billing"
+	if m.lockKey() == other.lockKey() {
+		t.Error("runners of different schemas share the migration lock")
+	}
+
+	m.config.LockKey = 7
+	if m.lockKey() != 7 {
+		t.Errorf("lockKey() = %d, want the configured key 7", m.lockKey())
+	}
+}
@@ -0,0 +1,135 @@
+package migratecmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/migration/config"
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "MIGRATION_"
+
+// option field of config.MigrationOptions, settable as flag --db-name, env MIGRATION_DB_NAME or yaml key dbName
+type option struct {
+	key   string
+	field int
+	value string
+	set   bool
+}
+
+// optionFlags register a flag for every mapstructure key of config.MigrationOptions
+func optionFlags(fs *flag.FlagSet) []*option {
+	t := reflect.TypeOf(config.MigrationOptions{})
+	options := []*option{}
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if key == "" || key == "-" {
+			continue
+		}
+		o := &option{key: key, field: i}
+		fs.Func(words(key, "-"), fmt.Sprintf("%s, env %s", key, envName(key)), func(value string) error {
+			o.value, o.set = value, true
+			return nil
+		})
+		options = append(options, o)
+	}
+	return options
+}
+
+// loadConfig resolve options from flags, then env, then the section of the yaml file
+func loadConfig(options []*option, file string, section string) (*config.MigrationOptions, error) {
+	values := map[string]any{}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.WrapIf(err, "failed to read config file")
+		}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, errors.WrapIf(err, "failed to parse config file")
+		}
+		for _, key := range strings.FieldsFunc(section, func(r rune) bool { return r == '.' }) {
+			sub, ok := values[key].(map[string]any)
+			if !ok {
+				return nil, errors.Errorf("config section %s not found in %s", section, file)
+			}
+			values = sub
+		}
+	}
+
+	cfg := &config.MigrationOptions{}
+	v := reflect.ValueOf(cfg).Elem()
+	for _, o := range options {
+		value, ok := o.value, o.set
+		if !ok {
+			value, ok = os.LookupEnv(envName(o.key))
+		}
+		if !ok {
+			var raw any
+			if raw, ok = values[o.key]; ok {
+				value = fmt.Sprint(raw)
+			}
+		}
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(o.field), value); err != nil {
+			return nil, errors.WrapIf(err, fmt.Sprintf("invalid value for %s", o.key))
+		}
+	}
+	return cfg, nil
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	default:
+		return errors.Errorf("unsupported option type %s", field.Type())
+	}
+	return nil
+}
+
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(words(key, "_"))
+}
+
+// words split a camelCase key into lower case words joined by sep, e.g. dbName -> db-name
+func words(key string, sep string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,326 @@
+// Package migratecmd the migrate command running the migrations of package migration with the settings
+// of config.MigrationOptions
+//
+//	migrate [flags] create <name>
+//	migrate [flags] up [version]
+//	migrate [flags] down [version]
+//	migrate [flags] steps <n>
+//	migrate [flags] force <version>
+//	migrate [flags] status
+//
+// Settings are read from flags, then MIGRATION_* env variables, then the yaml file of --config.
+//
+// Go migrations run only when they are registered in the binary, services with Go migrations build
+// their own command importing the package registering them:
+//
+//	import _ "example.com/service/migrations"
+//
+//	func main() {
+//		migratecmd.Main()
+//	}
+//
+// A binary without them refuses to migrate a database where migrations unknown to it were applied.
+package migratecmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/migration"
+	"github.com/go-thread-7/commonlib/migration/contracts"
+)
+
+// Main run the command with the arguments of the process and exit with status 1 on error
+func Main() {
+	if err := Run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Run run the command of args, the arguments without the program name
+func Run(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	configFile := fs.String("config", "", "yaml config file")
+	section := fs.String("section", "", "dot separated key of the migration options in the config file, e.g. postgres.migration")
+	dryRun := fs.Bool("dry-run", false, "print the sql that would run without running it")
+	options := optionFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: migrate [flags] create <name> | up [version] | down [version] | steps <n> | force <version> | status")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseArgs(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return errors.New("command is required")
+	}
+	command, commandArgs := positional[0], positional[1:]
+
+	cfg, err := loadConfig(options, *configFile, *section)
+	if err != nil {
+		return err
+	}
+	// skipMigration is meant for service startup, running the command is explicit
+	cfg.SkipMigration = false
+
+	if command == "create" {
+		if len(commandArgs) != 1 {
+			return errors.New("usage: migrate create <name>")
+		}
+		files, err := migration.Create(cfg.MigrationsDir, commandArgs[0])
+		for _, file := range files {
+			fmt.Println(file)
+		}
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runner, err := migration.New(cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := runner.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+
+	switch command {
+	case "up", "down", "steps":
+		if err := checkApplied(ctx, runner); err != nil {
+			return err
+		}
+	}
+
+	switch command {
+	case "up":
+		version, err := optionalVersion(commandArgs)
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			return printTargetPlan(ctx, runner, version, true)
+		}
+		return runner.Up(ctx, version)
+	case "down":
+		version, err := optionalVersion(commandArgs)
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			return printTargetPlan(ctx, runner, version, false)
+		}
+		return runner.Down(ctx, version)
+	case "steps":
+		n, err := requiredInt(commandArgs, "usage: migrate steps <n>")
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errors.New("steps must not be 0")
+		}
+		if *dryRun {
+			planned, err := runner.Plan(ctx, n)
+			if err != nil {
+				return err
+			}
+			printPlan(planned, func(uint) bool { return true })
+			return nil
+		}
+		return runner.Steps(ctx, n)
+	case "force":
+		version, err := requiredInt(commandArgs, "usage: migrate force <version>")
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			fmt.Printf("-- would force version %d without running migrations\n", version)
+			return nil
+		}
+		return runner.Force(ctx, version)
+	case "status":
+		return printStatus(ctx, runner)
+	default:
+		fs.Usage()
+		return errors.Errorf("unknown command %s", command)
+	}
+}
+
+// parseArgs parse flags placed before, between and after the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func optionalVersion(args []string) (uint, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	version, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, errors.WrapIf(err, "invalid version")
+	}
+	return uint(version), nil
+}
+
+func requiredInt(args []string, usage string) (int, error) {
+	if len(args) != 1 {
+		return 0, errors.New(usage)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, errors.WrapIf(err, usage)
+	}
+	return n, nil
+}
+
+// checkApplied refuse to migrate when applied migrations are unknown to the binary, e.g. Go migrations
+// registered by the service only, migrating without them would skip or never revert them
+func checkApplied(ctx context.Context, runner contracts.PostgresMigrationRunner) error {
+	drifts, err := runner.Verify(ctx)
+	if err != nil {
+		return err
+	}
+
+	unknown := []string{}
+	for _, drift := range drifts {
+		if drift.Current == "" {
+			unknown = append(unknown, fmt.Sprint(drift.Version))
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	return errors.Errorf("applied migrations %s are unknown to this command: run the migrate command of the service "+
+		"registering its Go migrations, or restore the missing migration files", strings.Join(unknown, ", "))
+}
+
+// printTargetPlan print the migrations Up(version) or Down(version) would run, version 0 means the latest
+// version up and no version down, like the real command migrating to a missing version fails and up
+// to a version below the current one migrates down
+func printTargetPlan(ctx context.Context, runner contracts.PostgresMigrationRunner, version uint, up bool) error {
+	if version == 0 {
+		n := 0
+		if !up {
+			n = -math.MaxInt32
+		}
+		planned, err := runner.Plan(ctx, n)
+		if err != nil {
+			return err
+		}
+		printPlan(planned, func(uint) bool { return true })
+		return nil
+	}
+
+	status, err := runner.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if version > status.Version && !up {
+		return errors.Errorf("cannot migrate down to version %d above current version %d", version, status.Version)
+	}
+
+	n := 0
+	include := func(v uint) bool { return v <= version }
+	if version < status.Version {
+		n = -math.MaxInt32
+		include = func(v uint) bool { return v > version }
+	}
+	planned, err := runner.Plan(ctx, n)
+	if err != nil {
+		return err
+	}
+	if version != status.Version && !containsVersion(planned, version) {
+		return errors.Errorf("no migration found for version %d", version)
+	}
+	printPlan(planned, include)
+	return nil
+}
+
+func containsVersion(planned []contracts.PlannedMigration, version uint) bool {
+	for _, m := range planned {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// printPlan print the sql of the planned migrations accepted by include, in the order they would run
+func printPlan(planned []contracts.PlannedMigration, include func(version uint) bool) {
+	count := 0
+	for _, m := range planned {
+		if !include(m.Version) {
+			break
+		}
+		count++
+		fmt.Printf("-- migration %d %s %s\n", m.Version, m.Identifier, m.Direction)
+		if body := strings.TrimSpace(m.Body); body != "" {
+			fmt.Println(body)
+		} else {
+			fmt.Println("-- no sql, only the version changes")
+		}
+		fmt.Println()
+	}
+	if count == 0 {
+		fmt.Println("-- no change")
+	}
+}
+
+func printStatus(ctx context.Context, runner contracts.PostgresMigrationRunner) error {
+	status, err := runner.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version: %d\n", status.Version)
+	fmt.Printf("dirty:   %t\n", status.Dirty)
+	if len(status.Pending) == 0 {
+		fmt.Println("pending: none")
+	} else {
+		fmt.Printf("pending: %d\n", len(status.Pending))
+		for _, version := range status.Pending {
+			fmt.Printf("  %d\n", version)
+		}
+	}
+	if len(status.Drift) == 0 {
+		fmt.Println("drift:   none")
+		return nil
+	}
+	fmt.Printf("drift:   %d\n", len(status.Drift))
+	for _, drift := range status.Drift {
+		if drift.Current == "" {
+			fmt.Printf("  %d missing\n", drift.Version)
+		} else {
+			fmt.Printf("  %d changed\n", drift.Version)
+		}
+	}
+	return nil
+}
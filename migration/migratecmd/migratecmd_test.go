@@ -0,0 +1,87 @@
+package migratecmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-thread-7/commonlib/migration/contracts"
+)
+
+// fakeRunner runner at version over the migrations 1, 2 and 3
+type fakeRunner struct {
+	contracts.PostgresMigrationRunner
+	version uint
+	drift   []contracts.ChecksumDrift
+}
+
+func (r *fakeRunner) Status(context.Context) (*contracts.MigrationStatus, error) {
+	status := &contracts.MigrationStatus{Version: r.version, Pending: []uint{}}
+	for v := r.version + 1; v <= 3; v++ {
+		status.Pending = append(status.Pending, v)
+	}
+	return status, nil
+}
+
+func (r *fakeRunner) Plan(_ context.Context, n int) ([]contracts.PlannedMigration, error) {
+	planned := []contracts.PlannedMigration{}
+	if n >= 0 {
+		for v := r.version + 1; v <= 3 && (n == 0 || len(planned) < n); v++ {
+			planned = append(planned, contracts.PlannedMigration{Version: v, Direction: "up"})
+		}
+		return planned, nil
+	}
+	for v := r.version; v >= 1 && len(planned) < -n; v-- {
+		planned = append(planned, contracts.PlannedMigration{Version: v, Direction: "down"})
+	}
+	return planned, nil
+}
+
+func (r *fakeRunner) Verify(context.Context) ([]contracts.ChecksumDrift, error) {
+	return r.drift, nil
+}
+
+func TestPrintTargetPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint
+		target  uint
+		up      bool
+		wantErr string
+	}{
+		{name: "up to latest", version: 1, up: true},
+		{name: "up to version", version: 1, target: 2, up: true},
+		{name: "up to current version", version: 2, target: 2, up: true},
+		{name: "up below current version migrates down", version: 3, target: 1, up: true},
+		{name: "up to missing version", version: 1, target: 7, up: true, wantErr: "no migration found for version 7"},
+		{name: "down to version", version: 3, target: 1},
+		{name: "down above current version", version: 1, target: 2, wantErr: "above current version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := printTargetPlan(context.Background(), &fakeRunner{version: tt.version}, tt.target, tt.up)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckApplied(t *testing.T) {
+	runner := &fakeRunner{drift: []contracts.ChecksumDrift{
+		{Version: 2, Recorded: "a", Current: "b"},
+		{Version: 4, Recorded: "c"},
+	}}
+	err := checkApplied(context.Background(), runner)
+	if err == nil || !strings.Contains(err.Error(), "applied migrations 4 are unknown") {
+		t.Fatalf("error = %v, want unknown migration 4", err)
+	}
+
+	runner.drift = runner.drift[:1]
+	if err := checkApplied(context.Background(), runner); err != nil {
+		t.Fatalf("changed migration refused: %v", err)
+	}
+}
@@ -2,56 +2,198 @@ package migration
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"net/url"
+	"os"
+	"sort"
 
 	"github.com/go-thread-7/commonlib/migration/config"
 	"github.com/go-thread-7/commonlib/migration/contracts"
+	gormpostgres "gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"emperror.dev/errors"
-	"github.com/golang-migrate/migrate"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	_ "github.com/lib/pq"
 )
 
 type migrator struct {
-	config     *config.MigrationOptions
-	db         *gorm.DB
-	datasource string
-	migration  *migrate.Migrate
+	config *config.MigrationOptions
+	db     *gorm.DB
+	sqlDB  *sql.DB
+	ownsDB bool
+	// conn connection of the migrate driver, the migration lock, the checksums and the go migrations use it too
+	conn      *sql.Conn
+	source    source.Driver
+	migration *migrate.Migrate
 }
 
+// New create migration runner on the connection pool of db, or on a pool built from config when db is nil,
+// the version table is config.VersionTable in config.SchemaName, defaults are schema_migrations in the current schema.
+// The runner holds one connection of the pool until Close and runs all its statements on it, the pool of db
+// stays owned by the caller and is not closed by the runner, the pool built from config is closed by Close
 func New(config *config.MigrationOptions, db *gorm.DB) (contracts.PostgresMigrationRunner, error) {
-	datasource := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.DBName,
-	)
+	sqlDB, err := openDB(config, db)
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to initialize migration database")
+	}
+	m := &migrator{config: config, db: db, sqlDB: sqlDB, ownsDB: db == nil}
+
+	if err := m.init(); err != nil {
+		if m.ownsDB {
+			_ = sqlDB.Close()
+		}
+		return nil, err
+	}
+	return m, nil
+}
 
-	migration, err := migrate.New(fmt.Sprintf("file://%s", config.MigrationsDir), datasource)
+func (m *migrator) init() error {
+	driver, conn, err := newDatabaseDriver(m.config, m.sqlDB)
 	if err != nil {
-		return nil, errors.WrapIf(err, "failed to initialize migrator")
+		return errors.WrapIf(err, "failed to initialize migration database driver")
 	}
 
-	return &migrator{
-		config:     config,
-		db:         db,
-		datasource: datasource,
-		migration:  migration,
-	}, nil
+	sourceDriver, err := newSourceDriver(m.config)
+	if err != nil {
+		_ = driver.Close()
+		return errors.WrapIf(err, "failed to initialize migration source")
+	}
+
+	if migrations := registeredGoMigrations(); len(migrations) > 0 {
+		if m.db == nil {
+			// go migrations run on gorm, a pool built from config is wrapped
+			if m.db, err = gorm.Open(gormpostgres.New(gormpostgres.Config{Conn: m.sqlDB}), &gorm.Config{}); err != nil {
+				_ = driver.Close()
+				_ = sourceDriver.Close()
+				return errors.WrapIf(err, "failed to initialize go migrations connection")
+			}
+		}
+		goSourceDriver, err := newGoSource(sourceDriver, migrations)
+		if err != nil {
+			_ = driver.Close()
+			_ = sourceDriver.Close()
+			return errors.WrapIf(err, "failed to initialize migration source")
+		}
+		sourceDriver = goSourceDriver
+		driver = &goDatabase{Driver: driver, db: onConn(m.db, conn), migrations: migrations}
+	}
+
+	migration, err := migrate.NewWithInstance("migrations", sourceDriver, m.config.DBName, driver)
+	if err != nil {
+		_ = driver.Close()
+		_ = sourceDriver.Close()
+		return errors.WrapIf(err, "failed to initialize migrator")
+	}
+
+	m.conn = conn
+	m.source = sourceDriver
+	m.migration = migration
+	return nil
+}
+
+// onConn session of db running its statements on conn
+func onConn(db *gorm.DB, conn *sql.Conn) *gorm.DB {
+	session := db.Session(&gorm.Session{NewDB: true})
+	session.Statement.ConnPool = conn
+	return session
 }
 
-func (m *migrator) Up(_ context.Context, version uint) error {
+// Close release the connection held by the runner, the pool is closed only when the runner built it from config
+func (m *migrator) Close() error {
+	sourceErr, databaseErr := m.migration.Close()
+	err := errors.Combine(sourceErr, databaseErr)
+	if m.ownsDB {
+		err = errors.Append(err, m.sqlDB.Close())
+	}
+	return errors.WrapIf(err, "failed to close migration runner")
+}
+
+// newSourceDriver read migrations from config.MigrationsFS when set, from the config.MigrationsDir directory otherwise
+func newSourceDriver(config *config.MigrationOptions) (source.Driver, error) {
+	fsys, dir := migrationsFS(config)
+	return iofs.New(fsys, dir)
+}
+
+func migrationsFS(config *config.MigrationOptions) (fs.FS, string) {
+	if config.MigrationsFS == nil {
+		return os.DirFS(config.MigrationsDir), "."
+	}
+	if config.MigrationsDir == "" {
+		return config.MigrationsFS, "."
+	}
+	return config.MigrationsFS, config.MigrationsDir
+}
+
+func openDB(config *config.MigrationOptions, db *gorm.DB) (*sql.DB, error) {
+	if db != nil {
+		return db.DB()
+	}
+	return sql.Open("postgres", datasource(config))
+}
+
+// newDatabaseDriver driver on a connection taken from sqlDB, unlike postgres.WithInstance closing
+// the driver returns the connection to the pool without closing the pool
+func newDatabaseDriver(config *config.MigrationOptions, sqlDB *sql.DB) (database.Driver, *sql.Conn, error) {
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{
+		DatabaseName:    config.DBName,
+		SchemaName:      config.SchemaName,
+		MigrationsTable: config.VersionTable,
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	return driver, conn, nil
+}
+
+func datasource(config *config.MigrationOptions) string {
+	sslMode := "disable"
+	if config.SSLMode {
+		sslMode = "require"
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", sslMode)
+	if config.SchemaName != "" {
+		query.Set("search_path", config.SchemaName)
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.User, config.Password),
+		Host:     fmt.Sprintf("%s:%d", config.Host, config.Port),
+		Path:     config.DBName,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String()
+}
+
+// Up migrate to version, or to the latest version when version is 0, replicas waiting for
+// the migration lock find the migrations already applied by the lock holder
+func (m *migrator) Up(ctx context.Context, version uint) error {
 	if m.config.SkipMigration {
 		log.Println("database migration skipped")
 		return nil
 	}
 
-	err := m.executeCommand(config.Up, version)
+	err := m.guarded(ctx, "up", func() error {
+		return m.executeCommand(config.Up, version)
+	})
 	if errors.Is(err, migrate.ErrNoChange) {
 		return nil
 	}
@@ -59,18 +201,24 @@ func (m *migrator) Up(_ context.Context, version uint) error {
 		return errors.WrapIf(err, "failed to migrate database")
 	}
 
-	log.Println("migration finished")
+	current, _, err := m.version()
+	if err != nil {
+		return err
+	}
+	log.Printf("migration finished at version %d\n", current)
 
 	return nil
 }
 
-func (m *migrator) Down(_ context.Context, version uint) error {
+func (m *migrator) Down(ctx context.Context, version uint) error {
 	if m.config.SkipMigration {
 		log.Println("database migration skipped")
 		return nil
 	}
 
-	err := m.executeCommand(config.Down, version)
+	err := m.guarded(ctx, "down", func() error {
+		return m.executeCommand(config.Down, version)
+	})
 	if errors.Is(err, migrate.ErrNoChange) {
 		return nil
 	}
@@ -83,6 +231,167 @@ func (m *migrator) Down(_ context.Context, version uint) error {
 	return nil
 }
 
+// Status return current version, dirty flag, versions of the source not applied yet and drifted migrations
+func (m *migrator) Status(ctx context.Context) (*contracts.MigrationStatus, error) {
+	version, dirty, err := m.version()
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := m.sourceVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	drifts, err := m.Verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &contracts.MigrationStatus{Version: version, Dirty: dirty, Pending: []uint{}, Drift: drifts}
+	for _, v := range versions {
+		if v > version {
+			status.Pending = append(status.Pending, v)
+		}
+	}
+	return status, nil
+}
+
+// Steps apply the next n migrations, or revert the last -n migrations when n is negative
+func (m *migrator) Steps(ctx context.Context, n int) error {
+	if m.config.SkipMigration {
+		log.Println("database migration skipped")
+		return nil
+	}
+
+	err := m.guarded(ctx, "steps", func() error {
+		return m.translateError(m.migration.Steps(n))
+	})
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	if err != nil {
+		return errors.WrapIf(err, "failed to migrate database")
+	}
+
+	log.Printf("migrated %d steps\n", n)
+
+	return nil
+}
+
+// Force set version without running migrations and clear the dirty flag, -1 means no migration applied
+func (m *migrator) Force(ctx context.Context, version int) error {
+	err := m.withLock(ctx, "force", func() error {
+		if err := m.migration.Force(version); err != nil {
+			return err
+		}
+		return m.recordChecksums(ctx)
+	})
+	if err != nil {
+		return errors.WrapIf(err, "failed to force migration version")
+	}
+
+	log.Printf("migration version forced to %d\n", version)
+
+	return nil
+}
+
+// Drop drop everything in the database, including the migrations table
+func (m *migrator) Drop(ctx context.Context) error {
+	err := m.withLock(ctx, "drop", func() error {
+		return m.migration.Drop()
+	})
+	if err != nil {
+		return errors.WrapIf(err, "failed to drop database")
+	}
+
+	log.Println("database dropped")
+
+	return nil
+}
+
+// Plan return the migrations Steps(n) would run without running them, n == 0 plans all pending migrations up
+func (m *migrator) Plan(_ context.Context, n int) ([]contracts.PlannedMigration, error) {
+	version, dirty, err := m.migration.Version()
+	applied := err == nil
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, errors.WrapIf(err, "failed to read migration version")
+	}
+	if dirty {
+		return nil, m.translateError(migrate.ErrDirty{Version: int(version)})
+	}
+	if applied {
+		// like migrate, planning from a version missing in the source fails
+		r, _, err := m.source.ReadUp(version)
+		if err != nil {
+			return nil, errors.WrapIf(err, fmt.Sprintf("no migration found for version %d", version))
+		}
+		_ = r.Close()
+	}
+
+	planned := []contracts.PlannedMigration{}
+	if n >= 0 {
+		var next uint
+		if applied {
+			next, err = m.source.Next(version)
+		} else {
+			next, err = m.source.First()
+		}
+		for err == nil && (n == 0 || len(planned) < n) {
+			migration, readErr := readMigration(m.source.ReadUp, next, string(config.Up))
+			if readErr != nil {
+				return nil, readErr
+			}
+			planned = append(planned, migration)
+			next, err = m.source.Next(next)
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, errors.WrapIf(err, "failed to read migrations")
+		}
+		return planned, nil
+	}
+
+	for current := version; applied && len(planned) < -n; {
+		migration, err := readMigration(m.source.ReadDown, current, string(config.Down))
+		if err != nil {
+			return nil, err
+		}
+		planned = append(planned, migration)
+
+		prev, err := m.source.Prev(current)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, errors.WrapIf(err, "failed to read migrations")
+		}
+		current = prev
+	}
+	return planned, nil
+}
+
+// readMigration read the body of a migration, a missing file only changes the version like in migrate
+func readMigration(read func(uint) (io.ReadCloser, string, error), version uint, direction string) (contracts.PlannedMigration, error) {
+	migration := contracts.PlannedMigration{Version: version, Direction: direction}
+
+	r, identifier, err := read(version)
+	if errors.Is(err, os.ErrNotExist) {
+		return migration, nil
+	}
+	if err != nil {
+		return migration, errors.WrapIf(err, fmt.Sprintf("failed to read migration %d %s", version, direction))
+	}
+	defer r.Close()
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return migration, errors.WrapIf(err, fmt.Sprintf("failed to read migration %d %s", version, direction))
+	}
+	migration.Identifier = identifier
+	migration.Body = string(body)
+	return migration, nil
+}
+
 func (m *migrator) executeCommand(command config.CommandType, version uint) error {
 	var err error
 	switch command {
@@ -96,12 +405,13 @@ func (m *migrator) executeCommand(command config.CommandType, version uint) erro
 		if version == 0 {
 			err = m.migration.Down()
 		} else {
-			err = m.migration.Migrate(version)
+			err = m.migrateDown(version)
 		}
 	default:
 		err = errors.New("invalid migration direction")
 	}
 
+	err = m.translateError(err)
 	if err == migrate.ErrNoChange {
 		return nil
 	}
@@ -111,3 +421,74 @@ func (m *migrator) executeCommand(command config.CommandType, version uint) erro
 
 	return nil
 }
+
+// migrateDown revert migrations down to version, which must not be above the current version
+func (m *migrator) migrateDown(version uint) error {
+	current, _, err := m.version()
+	if err != nil {
+		return err
+	}
+	if version > current {
+		return errors.Errorf("cannot migrate down to version %d above current version %d", version, current)
+	}
+	return m.migration.Migrate(version)
+}
+
+func (m *migrator) version() (uint, bool, error) {
+	version, dirty, err := m.migration.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.WrapIf(err, "failed to read migration version")
+	}
+	return version, dirty, nil
+}
+
+// sourceVersions sorted versions of the migration files and registered Go migrations
+func (m *migrator) sourceVersions() ([]uint, error) {
+	fsys, dir := migrationsFS(m.config)
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to read migrations directory")
+	}
+
+	seen := map[uint]bool{}
+	versions := []uint{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		parsed, err := source.Parse(entry.Name())
+		if err != nil || seen[parsed.Version] {
+			continue
+		}
+		seen[parsed.Version] = true
+		versions = append(versions, parsed.Version)
+	}
+	for version := range goMigrations {
+		if !seen[version] {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions, nil
+}
+
+// translateError replace dirty errors with a DirtyError describing how to recover
+func (m *migrator) translateError(err error) error {
+	var dirty migrate.ErrDirty
+	if !errors.As(err, &dirty) {
+		return err
+	}
+
+	previous := -1
+	if versions, sourceErr := m.sourceVersions(); sourceErr == nil {
+		for _, v := range versions {
+			if int(v) < dirty.Version {
+				previous = int(v)
+			}
+		}
+	}
+	return &DirtyError{Version: uint(dirty.Version), PreviousVersion: previous}
+}
@@ -0,0 +1,277 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"emperror.dev/errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-thread-7/commonlib/migration/config"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/stub"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	gormpostgres "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newMockDB gorm db on sqlmock, statements are matched by regular expression
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	db, err := gorm.Open(gormpostgres.New(gormpostgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, mock
+}
+
+// expectVersionTable the version table check of the migrate driver
+func expectVersionTable(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(1) FROM information_schema.tables`)).
+		WithArgs("public", "schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func testOptions(files fstest.MapFS) *config.MigrationOptions {
+	return &config.MigrationOptions{DBName: "app", SchemaName: "public", VersionTable: "schema_migrations", MigrationsFS: files}
+}
+
+func TestCloseKeepsCallerPool(t *testing.T) {
+	db, mock := newMockDB(t)
+	expectVersionTable(mock)
+	files := fstest.MapFS{"1_init.up.sql": {Data: []byte("CREATE TABLE t (id int);")}}
+
+	runner, err := New(testOptions(files), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	if inUse := sqlDB.Stats().InUse; inUse != 1 {
+		t.Fatalf("connections in use by the runner = %d, want 1", inUse)
+	}
+
+	if err := runner.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if inUse := sqlDB.Stats().InUse; inUse != 0 {
+		t.Fatalf("connections in use after Close = %d, want 0", inUse)
+	}
+	if err := sqlDB.Ping(); err != nil {
+		t.Fatalf("caller pool closed by the runner: %v", err)
+	}
+}
+
+// failingDriver stub database failing the migrations containing FAIL, like a statement failing halfway
+type failingDriver struct {
+	*stub.Stub
+}
+
+func (d failingDriver) Run(migration io.Reader) error {
+	body, err := io.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(body), "FAIL") {
+		return errors.New("syntax error at or near FAIL")
+	}
+	return d.Stub.Run(bytes.NewReader(body))
+}
+
+var runnerFiles = fstest.MapFS{
+	"1_init.up.sql":    {Data: []byte("CREATE TABLE t (id int);")},
+	"1_init.down.sql":  {Data: []byte("DROP TABLE t;")},
+	"2_index.up.sql":   {Data: []byte("CREATE INDEX t_id ON t (id);")},
+	"2_index.down.sql": {Data: []byte("DROP INDEX t_id;")},
+	"3_fail.up.sql":    {Data: []byte("FAIL;")},
+}
+
+// newStubRunner runner migrating a stub database, the lock and the checksums are taken on sqlmock
+// whose expectations are matched in any order since checksums are recorded in map order
+func newStubRunner(t *testing.T) (*migrator, *stub.Stub, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	mock.MatchExpectationsInOrder(false)
+	sqlDB, _ := db.DB()
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sourceDriver, err := iofs.New(runnerFiles, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver, _ := stub.WithInstance(nil, &stub.Config{})
+	stubDriver := driver.(*stub.Stub)
+	migration, err := migrate.NewWithInstance("migrations", sourceDriver, "app", failingDriver{stubDriver})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &migrator{config: testOptions(runnerFiles), sqlDB: sqlDB, conn: conn, source: sourceDriver, migration: migration}, stubDriver, mock
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_try_advisory_lock($1)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"acquired"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// expectNoChecksums Verify of a database without checksums table
+func expectNoChecksums(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+}
+
+// expectRecordChecksums recording of the checksums of applied migrations, removed is the condition
+// removing the checksums of versions not applied
+func expectRecordChecksums(mock sqlmock.Sqlmock, removed string, version int64, applied ...uint) {
+	table := `"public"."schema_migrations_checksums"`
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS ` + table)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM ` + table + ` WHERE version ` + removed + ` $1`)).
+		WithArgs(version).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, v := range applied {
+		name := map[uint]string{1: "1_init.up.sql", 2: "2_index.up.sql", 3: "3_fail.up.sql"}[v]
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO `+table+` (version, checksum) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING`)).
+			WithArgs(int64(v), checksum(string(runnerFiles[name].Data))).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     int
+		dirty       bool
+		wantVersion uint
+		wantPending []uint
+	}{
+		{name: "nothing applied", version: -1, wantPending: []uint{1, 2, 3}},
+		{name: "applied", version: 2, wantVersion: 2, wantPending: []uint{3}},
+		{name: "dirty", version: 3, dirty: true, wantVersion: 3, wantPending: []uint{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, db, mock := newStubRunner(t)
+			db.CurrentVersion, db.IsDirty = tt.version, tt.dirty
+			expectNoChecksums(mock)
+
+			status, err := m.Status(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Version != tt.wantVersion || status.Dirty != tt.dirty || !equalVersions(status.Pending, tt.wantPending) {
+				t.Fatalf("Status() = %+v, want version %d, dirty %t, pending %v", status, tt.wantVersion, tt.dirty, tt.wantPending)
+			}
+			if len(status.Drift) != 0 {
+				t.Errorf("drift = %+v, want none", status.Drift)
+			}
+		})
+	}
+}
+
+func TestSteps(t *testing.T) {
+	m, db, mock := newStubRunner(t)
+	ctx := context.Background()
+
+	expectLock(mock)
+	expectNoChecksums(mock)
+	expectRecordChecksums(mock, ">", 2, 1, 2)
+	if err := m.Steps(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if db.CurrentVersion != 2 {
+		t.Fatalf("version after Steps(2) = %d, want 2", db.CurrentVersion)
+	}
+
+	expectLock(mock)
+	expectNoChecksums(mock)
+	expectRecordChecksums(mock, ">", 1, 1)
+	if err := m.Steps(ctx, -1); err != nil {
+		t.Fatal(err)
+	}
+	if db.CurrentVersion != 1 || string(db.LastRunMigration) != "DROP INDEX t_id;" {
+		t.Fatalf("Steps(-1) = version %d, last migration %q", db.CurrentVersion, db.LastRunMigration)
+	}
+}
+
+// a failing migration leaves the database dirty, the next migration fails with a DirtyError
+// until Force marks the repaired version as applied
+func TestDirtyErrorAndForce(t *testing.T) {
+	m, db, mock := newStubRunner(t)
+	ctx := context.Background()
+
+	expectLock(mock)
+	expectNoChecksums(mock)
+	expectRecordChecksums(mock, ">=", 3, 1, 2)
+	if err := m.Up(ctx, 0); err == nil {
+		t.Fatal("Up() with a failing migration succeeded")
+	}
+	if db.CurrentVersion != 3 || !db.IsDirty {
+		t.Fatalf("version after the failed migration = %d, dirty %t, want dirty 3", db.CurrentVersion, db.IsDirty)
+	}
+
+	expectLock(mock)
+	expectNoChecksums(mock)
+	expectRecordChecksums(mock, ">=", 3, 1, 2)
+	err := m.Up(ctx, 0)
+	var dirtyErr *DirtyError
+	if !errors.As(err, &dirtyErr) {
+		t.Fatalf("Up() of a dirty database error = %v, want DirtyError", err)
+	}
+	if dirtyErr.Version != 3 || dirtyErr.PreviousVersion != 2 {
+		t.Fatalf("DirtyError = %+v, want version 3 and previous version 2", dirtyErr)
+	}
+	if !strings.Contains(err.Error(), "Force(3)") || !strings.Contains(err.Error(), "Force(2)") {
+		t.Errorf("DirtyError does not explain the recovery: %v", err)
+	}
+
+	// the changes of migration 3 were repaired manually
+	expectLock(mock)
+	expectRecordChecksums(mock, ">", 3, 1, 2, 3)
+	if err := m.Force(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if db.CurrentVersion != 3 || db.IsDirty {
+		t.Fatalf("version after Force(3) = %d, dirty %t, want clean 3", db.CurrentVersion, db.IsDirty)
+	}
+
+	expectLock(mock)
+	expectNoChecksums(mock)
+	expectRecordChecksums(mock, ">", 3, 1, 2, 3)
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up() after Force = %v", err)
+	}
+}
+
+func TestDrop(t *testing.T) {
+	m, db, mock := newStubRunner(t)
+	db.CurrentVersion = 2
+
+	expectLock(mock)
+	if err := m.Drop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if db.CurrentVersion != database.NilVersion || !db.EqualSequence([]string{stub.DROP}) {
+		t.Fatalf("Drop() = version %d, sequence %v", db.CurrentVersion, db.MigrationSequence)
+	}
+}
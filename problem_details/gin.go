@@ -0,0 +1,84 @@
+package problemdetail
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// headerWriter defer the status line written by c.AbortWithStatus and c.AbortWithError until the body is written
+// or the request ends, so the middleware can still write the problem details with the content type
+type headerWriter struct {
+	gin.ResponseWriter
+	pending bool
+}
+
+func (w *headerWriter) WriteHeaderNow() {
+	if !w.ResponseWriter.Written() {
+		w.pending = true
+	}
+}
+
+func (w *headerWriter) Written() bool {
+	return w.pending || w.ResponseWriter.Written()
+}
+
+// GinMiddleware write the last error of c.Errors, or a panic of the next handlers, as problem details,
+// a status set by the handler, e.g. with c.AbortWithError, takes precedence over the status of the error,
+// nothing is written when the handler already wrote a response body. Panics are logged with their stack,
+// stack traces are written in the response only with debugErrorsResponse
+func GinMiddleware(debugErrorsResponse bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writer := &headerWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		defer func() {
+			c.Writer = writer.ResponseWriter
+
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+				err, ok := r.(error)
+				if !ok {
+					err = errors.Errorf("panic: %v", r)
+				} else {
+					err = errors.WithStack(err)
+				}
+				log.Printf("[problemdetail_GinMiddleware] %s %s panic: %v\n%s", c.Request.Method, c.Request.URL.Path, r, debug.Stack())
+				_ = c.Error(err)
+				c.Abort()
+				writeGinProblem(c, writer, err, http.StatusInternalServerError, debugErrorsResponse)
+				return
+			}
+
+			if len(c.Errors) == 0 {
+				if writer.pending {
+					writer.ResponseWriter.WriteHeaderNow()
+				}
+				return
+			}
+			statusCode, err := resolveStatusCode(c.Errors.Last().Err)
+			if status := c.Writer.Status(); status >= http.StatusBadRequest {
+				statusCode = status
+			}
+			writeGinProblem(c, writer, err, statusCode, debugErrorsResponse)
+		}()
+
+		c.Next()
+	}
+}
+
+func writeGinProblem(c *gin.Context, writer *headerWriter, err error, statusCode int, withStack bool) {
+	if writer.ResponseWriter.Written() {
+		log.Printf("[problemdetail_GinMiddleware] response of %s %s already written, error: %v\n", c.Request.Method, c.Request.URL.Path, err)
+		return
+	}
+
+	if _, writeErr := resolveProblemDetails(c.Writer, c.Request, err, statusCode, withStack); writeErr != nil {
+		log.Printf("[problemdetail_GinMiddleware] write problem details error: %v\n", writeErr)
+	}
+}
@@ -0,0 +1,140 @@
+package problemdetail
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+func TestGinMiddlewareStackTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		debug     bool
+		handler   gin.HandlerFunc
+		status    int
+		wantStack bool
+	}{
+		{name: "panic", handler: func(*gin.Context) { panic("boom") }, status: http.StatusInternalServerError},
+		{name: "panic with debug", debug: true, handler: func(*gin.Context) { panic("boom") }, status: http.StatusInternalServerError, wantStack: true},
+		{
+			name:    "error",
+			handler: func(c *gin.Context) { _ = c.Error(errors.New("failed")) },
+			status:  http.StatusInternalServerError,
+		},
+		{
+			name:      "error with debug",
+			debug:     true,
+			handler:   func(c *gin.Context) { _ = c.Error(errors.New("failed")) },
+			status:    http.StatusInternalServerError,
+			wantStack: true,
+		},
+		{
+			name: "status error",
+			handler: func(c *gin.Context) {
+				_ = c.Error(errors.Wrap(NewStatusError("name taken", http.StatusConflict), "create"))
+			},
+			status: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(GinMiddleware(tt.debug))
+			router.GET("/", tt.handler)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Fatalf("content type = %q", ct)
+			}
+			var problem ProblemDetail
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if (problem.StackTrace != "") != tt.wantStack {
+				t.Fatalf("stackTrace = %q, want stack %t", problem.StackTrace, tt.wantStack)
+			}
+		})
+	}
+}
+
+func TestResolveProblemDetailsWithoutStackTrace(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := errors.Wrap(NewStatusError("in progress", http.StatusConflict), "acquire")
+	if _, writeErr := ResolveProblemDetails(w, httptest.NewRequest(http.MethodPost, "/orders", nil), err); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	var problem ProblemDetail
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.StackTrace != "" {
+		t.Fatalf("stackTrace = %q, want none", problem.StackTrace)
+	}
+}
+
+// a response written before the error is kept as is, the problem details are not written after it
+func TestGinMiddlewareWrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+	}{
+		{
+			name: "error",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusCreated, gin.H{"id": 1})
+				_ = c.Error(NewStatusError("publish failed", http.StatusBadGateway))
+			},
+		},
+		{
+			name: "abort with error",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusCreated, gin.H{"id": 1})
+				_ = c.AbortWithError(http.StatusBadGateway, errors.New("publish failed"))
+			},
+		},
+		{
+			name: "panic",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusCreated, gin.H{"id": 1})
+				panic("publish failed")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(GinMiddleware(true))
+			router.POST("/orders", tt.handler)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", nil))
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusCreated)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+				t.Fatalf("content type = %q", ct)
+			}
+			if body := w.Body.String(); body != `{"id":1}` {
+				t.Fatalf("body = %q, want the response of the handler only", body)
+			}
+		})
+	}
+}
@@ -22,6 +22,30 @@ type ProblemDetail struct {
 var mappers = map[reflect.Type]func() ProblemDetailErr{}
 var mapperStatus = map[int]func() ProblemDetailErr{}
 
+// StatusCoder implemented by errors carrying their own http status code
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// statusError error with a fixed message and http status code
+type statusError struct {
+	message string
+	status  int
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+func (e *statusError) StatusCode() int {
+	return e.status
+}
+
+// NewStatusError create an error answered with status by problem details, meant for sentinel errors
+func NewStatusError(message string, status int) error {
+	return &statusError{message: message, status: status}
+}
+
 type ProblemDetailErr interface {
 	SetStatus(status int) ProblemDetailErr
 	GetStatus() int
@@ -119,52 +143,64 @@ func Map[T error](funcProblem func() ProblemDetailErr) {
 	mappers[reflect.TypeOf(*new(T))] = funcProblem
 }
 
-// ResolveProblemDetails retrieve and resolve error with format problem details error
+// ResolveProblemDetails retrieve and resolve error with format problem details error, stack traces are
+// never written, they are exposed only by GinMiddleware with debugErrorsResponse
 func ResolveProblemDetails(w http.ResponseWriter, r *http.Request, err error) (ProblemDetailErr, error) {
+	statusCode, err := resolveStatusCode(err)
+	return resolveProblemDetails(w, r, err, statusCode, false)
+}
 
-	var statusCode int = http.StatusInternalServerError
+// resolveStatusCode status code carried by err, 500 otherwise, echo errors are unwrapped
+func resolveStatusCode(err error) (int, error) {
 	var echoError *echo.HTTPError
+	var statusCoder StatusCoder
 	if errors.As(err, &echoError) {
-		statusCode = err.(*echo.HTTPError).Code
-		err = err.(*echo.HTTPError).Message.(error)
-	} /*else if errors.As(err, &ginError) {
-		var rw, ok = w.(gin.ResponseWriter)
-		if ok && rw.Written() {
-			statusCode = rw.Status()
+		if messageErr, ok := echoError.Message.(error); ok {
+			return echoError.Code, messageErr
 		}
-		if gin.Mode() == gin.TestMode {
-			var rw = w.(*httptest.ResponseRecorder)
-			if rw.Code != http.StatusOK {
-				statusCode = rw.Code
-			}
-		}
-		err = err.(*gin.Error).Err.(error)
-	}*/
+		return echoError.Code, err
+	}
+	if errors.As(err, &statusCoder) {
+		return statusCoder.StatusCode(), err
+	}
+	return http.StatusInternalServerError, err
+}
 
-	var mapCustomType, mapCustomTypeErr = setMapCustomType(w, r, err)
+// resolveProblemDetails write err as problem details, the stack trace of err is included only with withStack
+func resolveProblemDetails(w http.ResponseWriter, r *http.Request, err error, statusCode int, withStack bool) (ProblemDetailErr, error) {
+	var mapCustomType, mapCustomTypeErr = setMapCustomType(w, r, err, withStack)
 	if mapCustomType != nil {
 		return mapCustomType, mapCustomTypeErr
 	}
 
-	var mapStatus, mapStatusErr = setMapStatusCode(w, r, err, statusCode)
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		prob := newValidationProblem(r, validationErr)
+		if _, err := writeTo(w, prob); err != nil {
+			return nil, err
+		}
+		return prob, nil
+	}
+
+	var mapStatus, mapStatusErr = setMapStatusCode(w, r, err, statusCode, withStack)
 	if mapStatus != nil {
 		return mapStatus, mapStatusErr
 	}
 
-	p, err := setDefaultProblemDetails(w, r, err, statusCode)
+	p, err := setDefaultProblemDetails(w, r, err, statusCode, withStack)
 	if err != nil {
 		return nil, err
 	}
 	return p, err
 }
 
-func setMapCustomType(w http.ResponseWriter, r *http.Request, err error) (ProblemDetailErr, error) {
+func setMapCustomType(w http.ResponseWriter, r *http.Request, err error, withStack bool) (ProblemDetailErr, error) {
 
 	problemCustomType := mappers[reflect.TypeOf(err)]
 	if problemCustomType != nil {
 		prob := problemCustomType()
 
-		validationProblems(prob, err, r)
+		validationProblems(prob, err, r, withStack)
 
 		for k, v := range mapperStatus {
 			if k == prob.GetStatus() {
@@ -185,11 +221,11 @@ func setMapCustomType(w http.ResponseWriter, r *http.Request, err error) (Proble
 	return nil, err
 }
 
-func setMapStatusCode(w http.ResponseWriter, r *http.Request, err error, statusCode int) (ProblemDetailErr, error) {
+func setMapStatusCode(w http.ResponseWriter, r *http.Request, err error, statusCode int, withStack bool) (ProblemDetailErr, error) {
 	problemStatus := mapperStatus[statusCode]
 	if problemStatus != nil {
 		prob := problemStatus()
-		validationProblems(prob, err, r)
+		validationProblems(prob, err, r, withStack)
 		_, err = writeTo(w, prob)
 		if err != nil {
 			return nil, err
@@ -199,16 +235,19 @@ func setMapStatusCode(w http.ResponseWriter, r *http.Request, err error, statusC
 	return nil, err
 }
 
-func setDefaultProblemDetails(w http.ResponseWriter, r *http.Request, err error, statusCode int) (ProblemDetailErr, error) {
+func setDefaultProblemDetails(w http.ResponseWriter, r *http.Request, err error, statusCode int, withStack bool) (ProblemDetailErr, error) {
 	defaultProblem := func() ProblemDetailErr {
-		return &ProblemDetail{
-			Type:       getDefaultType(statusCode),
-			Status:     statusCode,
-			Detail:     err.Error(),
-			Title:      http.StatusText(statusCode),
-			Instance:   r.URL.RequestURI(),
-			StackTrace: errorsWithStack(err),
+		problem := &ProblemDetail{
+			Type:     getDefaultType(statusCode),
+			Status:   statusCode,
+			Detail:   err.Error(),
+			Title:    http.StatusText(statusCode),
+			Instance: r.URL.RequestURI(),
+		}
+		if withStack {
+			problem.StackTrace = errorsWithStack(err)
 		}
+		return problem
 	}
 	prob := defaultProblem()
 	_, err = writeTo(w, prob)
@@ -218,7 +257,7 @@ func setDefaultProblemDetails(w http.ResponseWriter, r *http.Request, err error,
 	return prob, err
 }
 
-func validationProblems(problem ProblemDetailErr, err error, r *http.Request) {
+func validationProblems(problem ProblemDetailErr, err error, r *http.Request, withStack bool) {
 	problem.SetDetail(err.Error())
 
 	if problem.GetStatus() == 0 {
@@ -233,7 +272,7 @@ func validationProblems(problem ProblemDetailErr, err error, r *http.Request) {
 	if problem.GetTitle() == "" {
 		problem.SetTitle(http.StatusText(problem.GetStatus()))
 	}
-	if problem.GetStackTrace() == "" {
+	if withStack && problem.GetStackTrace() == "" {
 		problem.SetStackTrace(errorsWithStack(err))
 	}
 }
@@ -0,0 +1,60 @@
+package problemdetail
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const validationTitle = "Validation Failed"
+
+// FieldError invalid field of a validation problem
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError invalid request, written as a 400 validation problem with the field errors
+type ValidationError struct {
+	Detail string
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldError := range e.Errors {
+		messages = append(messages, fieldError.Message)
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+func (e *ValidationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// ValidationProblemDetail problem details with the field errors of a validation problem
+type ValidationProblemDetail struct {
+	ProblemDetail
+	Errors []FieldError `json:"errors"`
+}
+
+func newValidationProblem(r *http.Request, err *ValidationError) *ValidationProblemDetail {
+	errs := err.Errors
+	if errs == nil {
+		errs = []FieldError{}
+	}
+	return &ValidationProblemDetail{
+		ProblemDetail: ProblemDetail{
+			Type:     fmt.Sprintf("https://httpstatuses.io/%d", http.StatusBadRequest),
+			Status:   http.StatusBadRequest,
+			Title:    validationTitle,
+			Detail:   err.Error(),
+			Instance: r.URL.RequestURI(),
+		},
+		Errors: errs,
+	}
+}
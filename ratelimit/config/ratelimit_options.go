@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+type RateLimitOptions struct {
+	// Algorithm tokenBucket or slidingWindow, default tokenBucket
+	Algorithm string `mapstructure:"algorithm"`
+	// Limit requests allowed per Window
+	Limit  int           `mapstructure:"limit"`
+	Window time.Duration `mapstructure:"window"`
+	// Burst capacity of the token bucket, default Limit
+	Burst     int    `mapstructure:"burst"`
+	KeyPrefix string `mapstructure:"keyPrefix"`
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+)
+
+// KeyFunc key of the rate limit of a request, requests with an empty key are not limited
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP limit each client ip, X-Forwarded-For is only used from the trusted proxies of the engine,
+// e.g. config.HTTPConfig.TrustedProxies of httpserver.NewServer
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByRoute limit each route, the registered path so that path parameters share the limit
+func KeyByRoute(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	return "route:" + c.Request.Method + " " + route
+}
+
+// KeyByUser limit each user returned by user, e.g. the subject of the token set by the auth middleware,
+// anonymous requests are limited by ip
+func KeyByUser(user func(c *gin.Context) string) KeyFunc {
+	return func(c *gin.Context) string {
+		if id := user(c); id != "" {
+			return "user:" + id
+		}
+		return KeyByIP(c)
+	}
+}
+
+// KeyBy combine keys, e.g. KeyBy(KeyByRoute, KeyByIP) to limit each client on each route
+func KeyBy(keys ...KeyFunc) KeyFunc {
+	return func(c *gin.Context) string {
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			part := key(c)
+			if part == "" {
+				return ""
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// Middleware gin middleware limiting requests by key, responses get the RateLimit headers and rejected
+// requests a 429 problem with Retry-After, requests are let through when the store fails
+func Middleware(limiter *Limiter, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), k)
+		if err != nil {
+			log.Printf("[ratelimit_Middleware] key %s error: %v\n", k, err)
+			c.Next()
+			return
+		}
+
+		for name, value := range limiter.headers(result) {
+			c.Header(name, value)
+		}
+		if !result.Allowed {
+			_, _ = problemdetail.ResolveProblemDetails(c.Writer, c.Request, &RateLimitedError{RetryAfter: result.RetryAfter})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+	httpserver "github.com/go-thread-7/commonlib/http/http-server"
+	httpconfig "github.com/go-thread-7/commonlib/http/http-server/config"
+	problemdetail "github.com/go-thread-7/commonlib/problem_details"
+	"github.com/go-thread-7/commonlib/ratelimit/config"
+)
+
+// failingStore store whose backend is down
+type failingStore struct{}
+
+func (failingStore) Allow(context.Context, string, config.RateLimitOptions) (*Result, error) {
+	return nil, errors.New("connection refused")
+}
+
+func newLimitedRouter(t *testing.T, store Store, key KeyFunc) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	limiter, err := NewLimiter(store, &config.RateLimitOptions{Limit: 1, Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.Use(Middleware(limiter, key))
+	router.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func TestMiddleware(t *testing.T) {
+	router := newLimitedRouter(t, NewMemoryStore(), KeyByIP)
+	request := func(ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("10.0.0.1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Policy") != "1;w=60" {
+		t.Fatalf("headers = %v", w.Header())
+	}
+
+	w = request("10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("headers = %v", w.Header())
+	}
+	var problem problemdetail.ProblemDetail
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Status != http.StatusTooManyRequests {
+		t.Fatalf("problem = %s, %v", w.Body, err)
+	}
+
+	if w := request("10.0.0.2"); w.Code != http.StatusOK {
+		t.Fatalf("status of another client = %d, want 200", w.Code)
+	}
+}
+
+func TestMiddlewareSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, err := NewLimiter(NewMemoryStore(), &config.RateLimitOptions{Limit: 1, Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httpserver.NewServer(&httpconfig.HTTPConfig{BasePath: "/"}, Middleware(limiter, KeyByIP))
+	server.Routes.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	codes := make([]int, 0, 2)
+	for _, forwardedFor := range []string{"203.0.113.1", "203.0.113.2"} {
+		r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, r)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Fatalf("statuses = %v, want [200 429], a forged X-Forwarded-For must not get another limit", codes)
+	}
+}
+
+func TestMiddlewarePassThrough(t *testing.T) {
+	tests := []struct {
+		name  string
+		store Store
+		key   KeyFunc
+	}{
+		{name: "empty key", store: NewMemoryStore(), key: func(*gin.Context) string { return "" }},
+		{name: "store error", store: failingStore{}, key: KeyByIP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newLimitedRouter(t, tt.store, tt.key)
+			for i := 0; i < 3; i++ {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("request %d: status = %d, want 200", i, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyBy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/orders", nil)
+	c.Request.RemoteAddr = "10.0.0.1:1234"
+
+	if got := KeyBy(KeyByRoute, KeyByIP)(c); got != "route:GET /orders|ip:10.0.0.1" {
+		t.Fatalf("key = %q", got)
+	}
+	anonymous := KeyByUser(func(*gin.Context) string { return "" })
+	if got := KeyBy(anonymous, func(*gin.Context) string { return "" })(c); got != "" {
+		t.Fatalf("key with an empty part = %q, want empty", got)
+	}
+	if got := anonymous(c); got != "ip:10.0.0.1" {
+		t.Fatalf("anonymous key = %q", got)
+	}
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"net"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// GrpcKeyFunc key of the rate limit of a call, calls with an empty key are not limited
+type GrpcKeyFunc func(ctx context.Context, fullMethod string) string
+
+// GrpcKeyByPeer limit each client ip
+func GrpcKeyByPeer(ctx context.Context, _ string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// GrpcKeyByMethod limit each method
+func GrpcKeyByMethod(_ context.Context, fullMethod string) string {
+	return "method:" + fullMethod
+}
+
+// GrpcKeyByUser limit each user returned by user, anonymous calls are limited by peer ip
+func GrpcKeyByUser(user func(ctx context.Context) string) GrpcKeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		if id := user(ctx); id != "" {
+			return "user:" + id
+		}
+		return GrpcKeyByPeer(ctx, fullMethod)
+	}
+}
+
+// GrpcKeyBy combine keys, e.g. GrpcKeyBy(GrpcKeyByMethod, GrpcKeyByPeer) to limit each client on each method
+func GrpcKeyBy(keys ...GrpcKeyFunc) GrpcKeyFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			part := key(ctx, fullMethod)
+			if part == "" {
+				return ""
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// UnaryServerInterceptor grpc interceptor limiting calls by key, e.g. grpcserver.New(cfg, grpc.ChainUnaryInterceptor(...)),
+// the RateLimit headers are sent as header metadata and rejected calls fail with ResourceExhausted and RetryInfo
+func UnaryServerInterceptor(limiter *Limiter, key GrpcKeyFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		header, err := allowCall(ctx, limiter, key, info.FullMethod)
+		if header != nil {
+			if setErr := grpc.SetHeader(ctx, header); setErr != nil {
+				log.Printf("[ratelimit_UnaryServerInterceptor] set header error: %v\n", setErr)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor grpc interceptor limiting the start of streams by key, e.g. grpc.ChainStreamInterceptor(...)
+func StreamServerInterceptor(limiter *Limiter, key GrpcKeyFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		header, err := allowCall(ss.Context(), limiter, key, info.FullMethod)
+		if header != nil {
+			if setErr := ss.SetHeader(header); setErr != nil {
+				log.Printf("[ratelimit_StreamServerInterceptor] set header error: %v\n", setErr)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// allowCall header metadata of the rate limit of the call, and the status error when it is rejected,
+// calls are let through when the store fails
+func allowCall(ctx context.Context, limiter *Limiter, key GrpcKeyFunc, fullMethod string) (metadata.MD, error) {
+	k := key(ctx, fullMethod)
+	if k == "" {
+		return nil, nil
+	}
+
+	result, err := limiter.Allow(ctx, k)
+	if err != nil {
+		log.Printf("[ratelimit_allowCall] key %s error: %v\n", k, err)
+		return nil, nil
+	}
+
+	header := metadata.MD{}
+	for name, value := range limiter.headers(result) {
+		header.Set(name, value)
+	}
+	if result.Allowed {
+		return header, nil
+	}
+
+	rateLimitedErr := &RateLimitedError{RetryAfter: result.RetryAfter}
+	st, detailsErr := status.New(codes.ResourceExhausted, rateLimitedErr.Error()).
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(result.RetryAfter)})
+	if detailsErr != nil {
+		return header, status.Error(codes.ResourceExhausted, rateLimitedErr.Error())
+	}
+	return header, st.Err()
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-thread-7/commonlib/ratelimit/config"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	limiter, err := NewLimiter(NewMemoryStore(), &config.RateLimitOptions{Limit: 1, Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interceptor := UnaryServerInterceptor(limiter, GrpcKeyBy(GrpcKeyByMethod, GrpcKeyByPeer))
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.Orders/Get"}
+	calls := 0
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		calls++
+		return "ok", nil
+	}
+	call := func(ip string) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}})
+		_, err := interceptor(ctx, nil, info, handler)
+		return err
+	}
+
+	if err := call("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = call("10.0.0.1")
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("error = %v, want ResourceExhausted", err)
+	}
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = info
+		}
+	}
+	if retryInfo == nil || (time.Minute-retryInfo.RetryDelay.AsDuration()).Abs() > time.Second {
+		t.Fatalf("details = %v, want RetryInfo of 1m", st.Details())
+	}
+
+	if err := call("10.0.0.2"); err != nil {
+		t.Fatalf("call of another peer: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("handler calls = %d, want 2", calls)
+	}
+
+	// calls without peer have no key and are not limited
+	for i := 0; i < 3; i++ {
+		if _, err := interceptor(context.Background(), nil, info, handler); err != nil {
+			t.Fatalf("call without peer: %v", err)
+		}
+	}
+}
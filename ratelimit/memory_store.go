@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-thread-7/commonlib/ratelimit/config"
+)
+
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+type window struct {
+	start    time.Time
+	previous int64
+	current  int64
+}
+
+// MemoryStore rate limit state of a single instance, stale keys are swept lazily
+type MemoryStore struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	buckets   map[string]*bucket
+	windows   map[string]*window
+	expires   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+		windows: map[string]*window{},
+		expires: map[string]time.Time{},
+	}
+}
+
+func (s *MemoryStore) Allow(_ context.Context, key string, options config.RateLimitOptions) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if options.Algorithm == SlidingWindow {
+		return s.slidingWindow(key, now, options), nil
+	}
+	return s.tokenBucket(key, now, options), nil
+}
+
+func (s *MemoryStore) tokenBucket(key string, now time.Time, o config.RateLimitOptions) *Result {
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(o.Burst), at: now}
+		s.buckets[key] = b
+	}
+
+	// refill Limit tokens per Window up to Burst
+	elapsed := now.Sub(b.at)
+	b.tokens = min(float64(o.Burst), b.tokens+elapsed.Seconds()*float64(o.Limit)/o.Window.Seconds())
+	b.at = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	s.expires[key] = now.Add(time.Duration(float64(o.Burst) / float64(o.Limit) * float64(o.Window)))
+	return tokenBucketResult(allowed, b.tokens, o)
+}
+
+func (s *MemoryStore) slidingWindow(key string, now time.Time, o config.RateLimitOptions) *Result {
+	start := now.Truncate(o.Window)
+	w, ok := s.windows[key]
+	if !ok {
+		w = &window{start: start}
+		s.windows[key] = w
+	}
+
+	switch {
+	case w.start.Equal(start):
+	case w.start.Add(o.Window).Equal(start):
+		w.previous, w.current, w.start = w.current, 0, start
+	default:
+		w.previous, w.current, w.start = 0, 0, start
+	}
+
+	elapsed := now.Sub(start)
+	count := float64(w.previous)*float64(o.Window-elapsed)/float64(o.Window) + float64(w.current)
+	allowed := count+1 <= float64(o.Limit)
+	if allowed {
+		w.current++
+		count++
+	}
+	s.expires[key] = start.Add(2 * o.Window)
+	return slidingWindowResult(allowed, w.previous, count, elapsed, o)
+}
+
+// sweep remove keys past their expiry, at most once per sweepInterval
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, expires := range s.expires {
+		if now.After(expires) {
+			delete(s.buckets, key)
+			delete(s.windows, key)
+			delete(s.expires, key)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-thread-7/commonlib/ratelimit/config"
+)
+
+// epoch start of a window of every tested length
+var epoch = time.Unix(1_700_000_040, 0)
+
+// step request at offset from epoch and its expected result
+type step struct {
+	at         time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+var algorithmTests = []struct {
+	name    string
+	options config.RateLimitOptions
+	steps   []step
+}{
+	{
+		name:    "token bucket",
+		options: config.RateLimitOptions{Algorithm: TokenBucket, Limit: 2, Window: time.Second},
+		steps: []step{
+			{at: 0, allowed: true, remaining: 1},
+			{at: 0, allowed: true, remaining: 0},
+			{at: 0, retryAfter: 500 * time.Millisecond},
+			// one token refilled every 500ms
+			{at: 500 * time.Millisecond, allowed: true, remaining: 0},
+			{at: 10 * time.Second, allowed: true, remaining: 1},
+		},
+	},
+	{
+		name:    "token bucket with burst",
+		options: config.RateLimitOptions{Algorithm: TokenBucket, Limit: 1, Window: time.Second, Burst: 3},
+		steps: []step{
+			{at: 0, allowed: true, remaining: 2},
+			{at: 0, allowed: true, remaining: 1},
+			{at: 0, allowed: true, remaining: 0},
+			{at: 0, retryAfter: time.Second},
+		},
+	},
+	{
+		name:    "sliding window",
+		options: config.RateLimitOptions{Algorithm: SlidingWindow, Limit: 4, Window: time.Minute},
+		steps: []step{
+			{at: 0, allowed: true, remaining: 3},
+			{at: time.Second, allowed: true, remaining: 2},
+			{at: 2 * time.Second, allowed: true, remaining: 1},
+			{at: 3 * time.Second, allowed: true, remaining: 0},
+			{at: 4 * time.Second, retryAfter: 56 * time.Second},
+			// half of the previous window counts 30s into the next one
+			{at: 90 * time.Second, allowed: true, remaining: 1},
+			{at: 90 * time.Second, allowed: true, remaining: 0},
+			// the previous window weighs 1 request less after 15s
+			{at: 90 * time.Second, retryAfter: 15 * time.Second},
+			{at: 105 * time.Second, allowed: true, remaining: 0},
+			// windows older than the previous one are forgotten
+			{at: 300 * time.Second, allowed: true, remaining: 3},
+		},
+	},
+}
+
+// testAlgorithms run the algorithm tests against the store of newStore, setNow moves its clock
+func testAlgorithms(t *testing.T, newStore func(t *testing.T) (Store, func(time.Time))) {
+	for _, tt := range algorithmTests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, setNow := newStore(t)
+			limiter, err := NewLimiter(store, &tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, s := range tt.steps {
+				setNow(epoch.Add(s.at))
+				result, err := limiter.Allow(context.Background(), "client")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != s.allowed || s.allowed && result.Remaining != s.remaining {
+					t.Fatalf("step %d: allowed %t remaining %d, want %t %d", i, result.Allowed, result.Remaining, s.allowed, s.remaining)
+				}
+				if !s.allowed && (result.RetryAfter-s.retryAfter).Abs() > time.Millisecond {
+					t.Fatalf("step %d: retry after %s, want %s", i, result.RetryAfter, s.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testAlgorithms(t, func(*testing.T) (Store, func(time.Time)) {
+		store := NewMemoryStore()
+		return store, func(now time.Time) { store.now = func() time.Time { return now } }
+	})
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	now := epoch
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	options := withDefaults(&config.RateLimitOptions{Limit: 1, Window: time.Second})
+
+	if _, err := store.Allow(context.Background(), "client", options); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(sweepInterval)
+	if _, err := store.Allow(context.Background(), "other", options); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.buckets["client"]; ok {
+		t.Fatal("expired key not swept")
+	}
+	if _, ok := store.buckets["other"]; !ok {
+		t.Fatal("live key swept")
+	}
+}
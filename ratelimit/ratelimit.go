@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/ratelimit/config"
+)
+
+const (
+	TokenBucket   = "tokenBucket"
+	SlidingWindow = "slidingWindow"
+
+	defaultLimit     = 100
+	defaultWindow    = time.Minute
+	defaultKeyPrefix = "ratelimit:"
+)
+
+// Result decision for one request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset time until the quota is fully available again
+	Reset time.Duration
+	// RetryAfter time until the next request is allowed, zero when allowed
+	RetryAfter time.Duration
+}
+
+// Store keep the rate limit state of keys
+type Store interface {
+	// Allow count a request of key with the algorithm of options
+	Allow(ctx context.Context, key string, options config.RateLimitOptions) (*Result, error)
+}
+
+// RateLimitedError request rejected by the rate limiter, written as 429 problem details
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %s", retryAfterSeconds(e.RetryAfter))
+}
+
+func (e *RateLimitedError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+// Limiter rate limit of one policy, keys are prefixed with options.KeyPrefix
+type Limiter struct {
+	store   Store
+	options config.RateLimitOptions
+}
+
+func NewLimiter(store Store, options *config.RateLimitOptions) (*Limiter, error) {
+	o := withDefaults(options)
+	if o.Algorithm != TokenBucket && o.Algorithm != SlidingWindow {
+		return nil, errors.Errorf("invalid rate limit algorithm %s", o.Algorithm)
+	}
+	return &Limiter{store: store, options: o}, nil
+}
+
+func withDefaults(options *config.RateLimitOptions) config.RateLimitOptions {
+	o := config.RateLimitOptions{}
+	if options != nil {
+		o = *options
+	}
+	if o.Algorithm == "" {
+		o.Algorithm = TokenBucket
+	}
+	if o.Limit <= 0 {
+		o.Limit = defaultLimit
+	}
+	if o.Window <= 0 {
+		o.Window = defaultWindow
+	}
+	if o.Burst <= 0 {
+		o.Burst = o.Limit
+	}
+	if o.KeyPrefix == "" {
+		o.KeyPrefix = defaultKeyPrefix
+	}
+	return o
+}
+
+// Allow count a request of key
+func (l *Limiter) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.store.Allow(ctx, l.options.KeyPrefix+key, l.options)
+}
+
+// Policy value of the RateLimit-Policy header, e.g. 100;w=60
+func (l *Limiter) Policy() string {
+	policy := fmt.Sprintf("%d;w=%d", l.options.Limit, int(math.Ceil(l.options.Window.Seconds())))
+	if l.options.Algorithm == TokenBucket && l.options.Burst != l.options.Limit {
+		policy += fmt.Sprintf(";burst=%d", l.options.Burst)
+	}
+	return policy
+}
+
+// headers RateLimit headers of result, with Retry-After when rejected
+func (l *Limiter) headers(result *Result) map[string]string {
+	headers := map[string]string{
+		"RateLimit-Limit":     fmt.Sprint(result.Limit),
+		"RateLimit-Remaining": fmt.Sprint(result.Remaining),
+		"RateLimit-Reset":     retryAfterSeconds(result.Reset),
+		"RateLimit-Policy":    l.Policy(),
+	}
+	if !result.Allowed {
+		headers["Retry-After"] = retryAfterSeconds(result.RetryAfter)
+	}
+	return headers
+}
+
+func retryAfterSeconds(d time.Duration) string {
+	return fmt.Sprint(int64(math.Ceil(d.Seconds())))
+}
+
+// tokenBucketResult result of a token bucket refilled with Limit tokens per Window up to Burst, holding tokens after the request
+func tokenBucketResult(allowed bool, tokens float64, o config.RateLimitOptions) *Result {
+	perToken := o.Window / time.Duration(o.Limit)
+	result := &Result{
+		Allowed:   allowed,
+		Limit:     o.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(o.Burst) - tokens) * float64(perToken)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	return result
+}
+
+// slidingWindowResult result of a sliding window counter, the previous window count is weighted
+// by its overlap with the sliding window, count is the weighted count after the request
+func slidingWindowResult(allowed bool, previous int64, count float64, elapsed time.Duration, o config.RateLimitOptions) *Result {
+	result := &Result{
+		Allowed:   allowed,
+		Limit:     o.Limit,
+		Remaining: max(0, o.Limit-int(math.Ceil(count))),
+		Reset:     2*o.Window - elapsed,
+	}
+	if !allowed {
+		// the previous window count decays linearly until the current window ends
+		current := count - float64(previous)*float64(o.Window-elapsed)/float64(o.Window)
+		retryAfter := o.Window - elapsed
+		if previous > 0 && current+1 <= float64(o.Limit) {
+			retryAfter = o.Window - elapsed - time.Duration((float64(o.Limit)-1-current)/float64(previous)*float64(o.Window))
+		}
+		result.RetryAfter = max(retryAfter, time.Millisecond)
+	}
+	return result
+}
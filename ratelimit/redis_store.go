@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-thread-7/commonlib/ratelimit/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refill and take a token atomically, the clock of redis is used so that
+// every instance sees the same time, returns allowed and the tokens left
+var tokenBucketScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * limit / window)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * window / limit))
+return {allowed, tostring(tokens)}
+`)
+
+// slidingWindowScript count the request in the current window weighted with the previous window,
+// both windows are kept in one hash, returns allowed, the previous count, the weighted count and
+// the milliseconds elapsed in the current window
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local start = now - (now % window)
+
+local state = redis.call('HMGET', KEYS[1], 'start', 'previous', 'current')
+local last = tonumber(state[1])
+local previous = tonumber(state[2]) or 0
+local current = tonumber(state[3]) or 0
+if last == nil then
+  previous = 0
+  current = 0
+elseif last + window == start then
+  previous = current
+  current = 0
+elseif last ~= start then
+  previous = 0
+  current = 0
+end
+
+local elapsed = now - start
+local count = previous * (window - elapsed) / window + current
+local allowed = 0
+if count + 1 <= limit then
+  current = current + 1
+  count = count + 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'start', start, 'previous', previous, 'current', current)
+redis.call('PEXPIRE', KEYS[1], start + 2 * window - now)
+return {allowed, previous, tostring(count), elapsed}
+`)
+
+// RedisStore rate limit state shared by every instance, e.g. with the client from redis.NewRedisClient,
+// requires redis 5 or later for scripts writing after TIME
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Allow(ctx context.Context, key string, options config.RateLimitOptions) (*Result, error) {
+	window := options.Window.Milliseconds()
+	if window <= 0 {
+		return nil, errors.Errorf("rate limit window %s is shorter than a millisecond", options.Window)
+	}
+
+	if options.Algorithm == SlidingWindow {
+		return s.slidingWindow(ctx, key, window, options)
+	}
+	return s.tokenBucket(ctx, key, window, options)
+}
+
+func (s *RedisStore) tokenBucket(ctx context.Context, key string, window int64, o config.RateLimitOptions) (*Result, error) {
+	values, err := tokenBucketScript.Run(ctx, s.client, []string{key}, o.Limit, window, o.Burst).Slice()
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to run token bucket script")
+	}
+	if len(values) != 2 {
+		return nil, errors.Errorf("unexpected token bucket script result %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	tokens, err := parseFloat(values[1])
+	if err != nil {
+		return nil, err
+	}
+	return tokenBucketResult(allowed == 1, tokens, o), nil
+}
+
+func (s *RedisStore) slidingWindow(ctx context.Context, key string, window int64, o config.RateLimitOptions) (*Result, error) {
+	values, err := slidingWindowScript.Run(ctx, s.client, []string{key}, o.Limit, window).Slice()
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to run sliding window script")
+	}
+	if len(values) != 4 {
+		return nil, errors.Errorf("unexpected sliding window script result %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	previous, _ := values[1].(int64)
+	count, err := parseFloat(values[2])
+	if err != nil {
+		return nil, err
+	}
+	elapsed, _ := values[3].(int64)
+	return slidingWindowResult(allowed == 1, previous, count, time.Duration(elapsed)*time.Millisecond, o), nil
+}
+
+func parseFloat(value any) (float64, error) {
+	s, _ := value.(string)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.WrapIf(err, "unexpected rate limit script result")
+	}
+	return f, nil
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-thread-7/commonlib/ratelimit/config"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return NewRedisStore(client), server
+}
+
+func TestRedisStore(t *testing.T) {
+	testAlgorithms(t, func(t *testing.T) (Store, func(time.Time)) {
+		store, server := newRedisStore(t)
+		return store, server.SetTime
+	})
+}
+
+func TestRedisStoreExpiry(t *testing.T) {
+	store, server := newRedisStore(t)
+	server.SetTime(epoch)
+
+	options := withDefaults(&config.RateLimitOptions{Algorithm: SlidingWindow, Limit: 1, Window: time.Minute})
+	if _, err := store.Allow(context.Background(), "client", options); err != nil {
+		t.Fatal(err)
+	}
+	// the state outlives the previous window only
+	if ttl := server.TTL("client"); ttl != 2*time.Minute {
+		t.Fatalf("ttl = %s, want 2m", ttl)
+	}
+
+	options.Window = time.Microsecond
+	if _, err := store.Allow(context.Background(), "client", options); err == nil {
+		t.Fatal("window shorter than a millisecond accepted")
+	}
+}